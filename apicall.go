@@ -0,0 +1,55 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+)
+
+// An apiCall is a CloudControl API call that changes a resource; when it ends, it is recorded (with its response) in the
+// audit log, and its duration is recorded in the metrics.
+type apiCall struct {
+	Action       string
+	ResourceType string
+	ResourceID   string
+	ResourceName string
+	Started      time.Time
+}
+
+// Begin a CloudControl API call that changes a resource.
+//
+// The resource Id may be empty if it is not known until the call ends (e.g. when deploying a resource).
+func beginAPICall(action string, resourceType string, resourceID string, resourceName string) *apiCall {
+	return &apiCall{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ResourceName: resourceName,
+		Started:      time.Now(),
+	}
+}
+
+// Begin a CloudControl API call that performs the action.
+func (action *actionLog) BeginAPICall() *apiCall {
+	return beginAPICall(action.Event.Action, action.Event.ResourceType, action.Event.ResourceID, action.Event.ResourceName)
+}
+
+// End the API call, recording its response and duration.
+func (call *apiCall) End(err error) {
+	metrics.RecordAPICall(call.ResourceType, call.Action, time.Since(call.Started), err)
+	audit.RecordAPICall(call.Action, call.ResourceType, call.ResourceID, call.ResourceName, err)
+}
@@ -0,0 +1,458 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// auditOptions represents the options for the "audit" command.
+type auditOptions struct {
+	Verify auditVerifyOptions `command:"verify" description:"Verify that an audit log has not been tampered with."`
+}
+
+// auditVerifyOptions represents the options for the "audit verify" command.
+type auditVerifyOptions struct {
+	Args struct {
+		AuditLog string `positional-arg-name:"audit-log" description:"The audit log to verify (defaults to --audit-log, if specified)."`
+	} `positional-args:"yes"`
+}
+
+// Audit events.
+const (
+	auditRunStarted  = "run-started"
+	auditTarget      = "target"
+	auditAPICall     = "api-call"
+	auditRunFinished = "run-finished"
+)
+
+// The previous hash for the first record in an audit log.
+const auditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// The audit log for the current run (nil if not auditing).
+var audit *auditLog
+
+// An auditRecord is a single entry in the audit log.
+//
+// Each record includes the hash of the previous record, and its own hash covers its content and the previous hash, so
+// removing, reordering, or modifying records breaks the chain.
+type auditRecord struct {
+	Sequence          int      `json:"sequence"`
+	Time              string   `json:"time"`
+	Event             string   `json:"event"`
+	RunID             string   `json:"runId"`
+	LocalUser         string   `json:"localUser,omitempty"`
+	Host              string   `json:"host,omitempty"`
+	Operator          string   `json:"operator,omitempty"`
+	Command           string   `json:"command,omitempty"`
+	Arguments         []string `json:"arguments,omitempty"`
+	Region            string   `json:"region,omitempty"`
+	DatacenterID      string   `json:"datacenterId,omitempty"`
+	NetworkDomainID   string   `json:"networkDomainId,omitempty"`
+	NetworkDomainName string   `json:"networkDomainName,omitempty"`
+	Action            string   `json:"action,omitempty"`
+	ResourceType      string   `json:"resourceType,omitempty"`
+	ResourceID        string   `json:"resourceId,omitempty"`
+	ResourceName      string   `json:"resourceName,omitempty"`
+	Response          string   `json:"response,omitempty"`
+	ExitCode          *int     `json:"exitCode,omitempty"`
+	PreviousHash      string   `json:"previousHash"`
+	Hash              string   `json:"hash"`
+}
+
+// The largest audit record that can be read back from an audit log.
+const maxAuditRecordSize = 1024 * 1024
+
+// auditLog appends hash-chained records to the audit log file (and, optionally, syslog).
+//
+// It is safe to use from multiple goroutines, and from multiple processes (e.g. the janitor and a manual nuke) sharing the
+// same audit log; the file is locked while each record is appended, and each record is chained to the last record in the
+// file at that time.
+type auditLog struct {
+	lock   sync.Mutex
+	file   *os.File
+	syslog io.Writer
+	runID  string
+}
+
+// Open the audit log (if one was specified), and record the start of the run.
+//
+// Commands that cannot change resources (e.g. "audit verify" or "reclaim-ips --dry-run") are not audited.
+func openAuditLog(options programOptions) error {
+	if options.AuditLog == "" || !options.ChangesResources() {
+		return nil
+	}
+
+	file, err := os.OpenFile(options.AuditLog, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open audit log '%s' (%s).", options.AuditLog, err)
+	}
+
+	runID := make([]byte, 8)
+	_, err = rand.Read(runID)
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	newAuditLog := &auditLog{
+		file:  file,
+		runID: hex.EncodeToString(runID),
+	}
+	if options.AuditSyslog {
+		newAuditLog.syslog, err = openAuditSyslog()
+		if err != nil {
+			file.Close()
+
+			return fmt.Errorf("Unable to open syslog for auditing (%s).", err)
+		}
+	}
+
+	record := auditRecord{
+		Event:             auditRunStarted,
+		Operator:          os.Getenv("MCP_USER"),
+		Command:           options.Command,
+		Arguments:         redactArguments(os.Args[1:]),
+		Region:            options.Region,
+		DatacenterID:      options.Datacenter,
+		NetworkDomainName: options.NetworkDomain,
+	}
+	if currentUser, err := user.Current(); err == nil {
+		record.LocalUser = currentUser.Username
+	} else {
+		record.LocalUser = os.Getenv("USER")
+	}
+	record.Host, _ = os.Hostname()
+
+	err = newAuditLog.append(record)
+	if err != nil {
+		file.Close()
+
+		return err
+	}
+
+	audit = newAuditLog
+
+	return nil
+}
+
+// RecordTarget records the network domain targeted by a nuke.
+func (auditLog *auditLog) RecordTarget(networkDomain *compute.NetworkDomain) {
+	if auditLog == nil {
+		return
+	}
+
+	auditLog.appendOrWarn(auditRecord{
+		Event:             auditTarget,
+		DatacenterID:      networkDomain.DatacenterID,
+		NetworkDomainID:   networkDomain.ID,
+		NetworkDomainName: networkDomain.Name,
+	})
+}
+
+// RecordAPICall records a mutating CloudControl API call and its response.
+func (auditLog *auditLog) RecordAPICall(action string, resourceType string, resourceID string, resourceName string, err error) {
+	if auditLog == nil {
+		return
+	}
+
+	response := "OK"
+	if err != nil {
+		response = err.Error()
+	}
+
+	auditLog.appendOrWarn(auditRecord{
+		Event:        auditAPICall,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		ResourceName: resourceName,
+		Response:     response,
+	})
+}
+
+// RunFinished records the outcome of the run and closes the audit log.
+func (auditLog *auditLog) RunFinished(err error) {
+	if auditLog == nil {
+		return
+	}
+
+	exitCode := exitCodeForError(err)
+	record := auditRecord{
+		Event:    auditRunFinished,
+		ExitCode: &exitCode,
+		Response: "OK",
+	}
+	if err != nil {
+		record.Response = err.Error()
+	}
+	auditLog.appendOrWarn(record)
+
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+
+	auditLog.file.Close()
+}
+
+// Append a record to the audit log, logging (rather than returning) any error.
+func (auditLog *auditLog) appendOrWarn(record auditRecord) {
+	err := auditLog.append(record)
+	if err != nil {
+		logger.Printf("WARNING - failed to write to audit log: %s", err)
+	}
+}
+
+// Append a record to the audit log (and syslog, if enabled).
+func (auditLog *auditLog) append(record auditRecord) error {
+	auditLog.lock.Lock()
+	defer auditLog.lock.Unlock()
+
+	data, err := auditLog.appendToFile(record)
+	if err != nil {
+		return err
+	}
+
+	if auditLog.syslog != nil {
+		_, err = auditLog.syslog.Write(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Append a record to the audit log file (while holding an exclusive lock on it), returning the record's JSON.
+func (auditLog *auditLog) appendToFile(record auditRecord) ([]byte, error) {
+	err := lockAuditLogFile(auditLog.file)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to lock audit log '%s' (%s).", auditLog.file.Name(), err)
+	}
+	defer unlockAuditLogFile(auditLog.file)
+
+	// Another process may have appended records since we last did.
+	sequence, previousHash, err := readAuditLogTail(auditLog.file)
+	if err != nil {
+		return nil, err
+	}
+
+	record.Sequence = sequence + 1
+	record.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	record.RunID = auditLog.runID
+	record.PreviousHash = previousHash
+
+	record.Hash, err = hashAuditRecord(record)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	_, err = auditLog.file.Write(append(data, '\n'))
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Compute the hash of an audit record (a hex-encoded SHA-256 of its JSON, excluding the hash itself).
+func hashAuditRecord(record auditRecord) (string, error) {
+	record.Hash = ""
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(data)
+
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// Read the sequence number and hash of the last record in an audit log (so that a new record can be chained to it).
+//
+// Only the end of the file is read, so this stays cheap as the audit log grows.
+func readAuditLogTail(file *os.File) (sequence int, previousHash string, err error) {
+	lastLine, err := readLastLine(file, maxAuditRecordSize)
+	if err != nil {
+		return 0, "", fmt.Errorf("Unable to read audit log '%s' (%s).", file.Name(), err)
+	}
+	if lastLine == "" {
+		return 0, auditGenesisHash, nil
+	}
+
+	var lastRecord auditRecord
+	err = json.Unmarshal([]byte(lastLine), &lastRecord)
+	if err != nil {
+		return 0, "", fmt.Errorf("Audit log '%s' is corrupt (the last record is not valid JSON); use 'nifo audit verify' to check it.", file.Name())
+	}
+
+	return lastRecord.Sequence, lastRecord.Hash, nil
+}
+
+// Read the last non-empty line of a file (which must be no longer than maxLength bytes).
+func readLastLine(file *os.File, maxLength int64) (string, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	end := info.Size()
+	start := end - maxLength
+	if start < 0 {
+		start = 0
+	}
+	buffer := make([]byte, end-start)
+	_, err = file.ReadAt(buffer, start)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(buffer), "\r\n \t"), "\n")
+	if len(lines) == 1 && start > 0 {
+		return "", fmt.Errorf("The last line is longer than %d bytes.", maxLength)
+	}
+
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// Verify that the records in an audit log form an unbroken hash chain.
+func verifyAuditLog(options programOptions) error {
+	auditLogFile := options.Audit.Verify.Args.AuditLog
+	if auditLogFile == "" {
+		auditLogFile = options.AuditLog
+	}
+	if auditLogFile == "" {
+		return &badOptionsError{
+			Err: fmt.Errorf("Must specify the audit log to verify."),
+		}
+	}
+
+	file, err := os.Open(auditLogFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	previousHash := auditGenesisHash
+	sequence := 0
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxAuditRecordSize)
+	for scanner.Scan() {
+		lineNumber++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record auditRecord
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return fmt.Errorf("Audit log '%s' is invalid: line %d is not a valid record.", auditLogFile, lineNumber)
+		}
+		if record.Sequence != sequence+1 {
+			return fmt.Errorf("Audit log '%s' is invalid: line %d has sequence number %d (expected %d); records have been removed or reordered.",
+				auditLogFile, lineNumber, record.Sequence, sequence+1,
+			)
+		}
+		if record.PreviousHash != previousHash {
+			return fmt.Errorf("Audit log '%s' is invalid: line %d does not follow the previous record; records have been removed or reordered.",
+				auditLogFile, lineNumber,
+			)
+		}
+		hash, err := hashAuditRecord(record)
+		if err != nil {
+			return err
+		}
+		if record.Hash != hash {
+			return fmt.Errorf("Audit log '%s' is invalid: line %d has been modified.", auditLogFile, lineNumber)
+		}
+
+		sequence = record.Sequence
+		previousHash = record.Hash
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Audit log '%s' is intact (%d record(s)).", auditLogFile, sequence)
+
+	return nil
+}
+
+// Command-line options whose values are secrets (matched against the option name, without the leading dashes).
+var secretArgumentName = regexp.MustCompile(`(?i)(password|secret|token|key)`)
+
+// Redact the values of secret command-line options (e.g. --api-token).
+//
+// Only option names are checked; other options and positional arguments are recorded as-is.
+func redactArguments(args []string) []string {
+	redacted := make([]string, len(args))
+	redactNext := false
+	for index, arg := range args {
+		redacted[index] = arg
+		if redactNext {
+			redacted[index] = "REDACTED"
+			redactNext = false
+
+			continue
+		}
+		if arg == "--" {
+			copy(redacted[index:], args[index:]) // Everything after "--" is a positional argument.
+
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		name := strings.TrimLeft(arg, "-")
+		separator := strings.Index(name, "=")
+		if separator != -1 {
+			name = name[:separator]
+		}
+		if !secretArgumentName.MatchString(name) {
+			continue
+		}
+
+		if separator == -1 {
+			redactNext = true // The value is the next argument.
+		} else {
+			redacted[index] = arg[:strings.Index(arg, "=")+1] + "REDACTED"
+		}
+	}
+
+	return redacted
+}
@@ -0,0 +1,54 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// LockFileEx flag requesting an exclusive lock.
+const lockfileExclusiveLock = 0x00000002
+
+// Take an exclusive lock on the audit log file (blocking until it is available).
+func lockAuditLogFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	result, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock, 0, 0xFFFFFFFF, 0xFFFFFFFF, uintptr(unsafe.Pointer(&overlapped)))
+	if result == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// Release the lock on the audit log file.
+func unlockAuditLogFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	result, _, err := procUnlockFileEx.Call(file.Fd(), 0, 0xFFFFFFFF, 0xFFFFFFFF, uintptr(unsafe.Pointer(&overlapped)))
+	if result == 0 {
+		return err
+	}
+
+	return nil
+}
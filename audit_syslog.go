@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// Open syslog for writing audit records.
+func openAuditSyslog() (io.Writer, error) {
+	return syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTH, "nifo")
+}
@@ -0,0 +1,289 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Create an audit log in a temporary directory (the caller must remove the directory).
+func createTestAuditLog(t *testing.T, runID string) (*auditLog, string) {
+	directory, err := ioutil.TempDir("", "nifo-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return openTestAuditLog(t, filepath.Join(directory, "audit.log"), runID), directory
+}
+
+// Open an existing (or new) audit log for appending.
+func openTestAuditLog(t *testing.T, auditLogFile string, runID string) *auditLog {
+	file, err := os.OpenFile(auditLogFile, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &auditLog{
+		file:  file,
+		runID: runID,
+	}
+}
+
+// Verify the specified audit log.
+func verifyTestAuditLog(auditLogFile string) error {
+	options := programOptions{}
+	options.Audit.Verify.Args.AuditLog = auditLogFile
+
+	return verifyAuditLog(options)
+}
+
+// Read the records (lines) from an audit log.
+func readTestAuditLogLines(t *testing.T, auditLogFile string) []string {
+	data, err := ioutil.ReadFile(auditLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+// Overwrite an audit log with the specified records (lines).
+func writeTestAuditLogLines(t *testing.T, auditLogFile string, lines []string) {
+	err := ioutil.WriteFile(auditLogFile, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuditLogVerify(t *testing.T) {
+	testAuditLog, directory := createTestAuditLog(t, "run1")
+	defer os.RemoveAll(directory)
+	auditLogFile := testAuditLog.file.Name()
+
+	testAuditLog.appendOrWarn(auditRecord{Event: auditRunStarted, Command: "reap"})
+	testAuditLog.RecordAPICall("delete", "natRule", "nat1", "", nil)
+	testAuditLog.RecordAPICall("delete", "server", "server1", "web-1", nil)
+	testAuditLog.RunFinished(nil)
+
+	err := verifyTestAuditLog(auditLogFile)
+	if err != nil {
+		t.Fatalf("Expected untouched audit log to verify, but got: %s", err)
+	}
+	lines := readTestAuditLogLines(t, auditLogFile)
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 records, but found %d", len(lines))
+	}
+
+	testCases := []struct {
+		Name     string
+		Lines    []string
+		Expected string
+	}{
+		{
+			Name:     "modified",
+			Lines:    []string{lines[0], strings.Replace(lines[1], "nat1", "nat2", 1), lines[2], lines[3]},
+			Expected: "line 2 has been modified",
+		},
+		{
+			Name:     "deleted",
+			Lines:    []string{lines[0], lines[2], lines[3]},
+			Expected: "line 2 has sequence number 3",
+		},
+		{
+			Name:     "reordered",
+			Lines:    []string{lines[0], lines[2], lines[1], lines[3]},
+			Expected: "records have been removed or reordered",
+		},
+		{
+			Name:     "not JSON",
+			Lines:    []string{lines[0], "not a record", lines[2], lines[3]},
+			Expected: "line 2 is not a valid record",
+		},
+	}
+	for _, testCase := range testCases {
+		writeTestAuditLogLines(t, auditLogFile, testCase.Lines)
+
+		err := verifyTestAuditLog(auditLogFile)
+		if err == nil || !strings.Contains(err.Error(), testCase.Expected) {
+			t.Errorf("%s: expected error containing '%s', but got: %v", testCase.Name, testCase.Expected, err)
+		}
+	}
+}
+
+func TestAuditLogConcurrentWriters(t *testing.T) {
+	firstAuditLog, directory := createTestAuditLog(t, "run1")
+	defer os.RemoveAll(directory)
+	auditLogFile := firstAuditLog.file.Name()
+
+	// Simulate two processes (e.g. the janitor and a manual nuke) sharing the same audit log.
+	secondAuditLog := openTestAuditLog(t, auditLogFile, "run2")
+
+	var waitGroup sync.WaitGroup
+	for _, testAuditLog := range []*auditLog{firstAuditLog, secondAuditLog} {
+		waitGroup.Add(1)
+		go func(testAuditLog *auditLog) {
+			defer waitGroup.Done()
+
+			for index := 0; index < 50; index++ {
+				testAuditLog.RecordAPICall("delete", "natRule", "nat", "", nil)
+			}
+		}(testAuditLog)
+	}
+	waitGroup.Wait()
+	firstAuditLog.RunFinished(nil)
+	secondAuditLog.RunFinished(nil)
+
+	err := verifyTestAuditLog(auditLogFile)
+	if err != nil {
+		t.Fatalf("Expected audit log written by concurrent writers to verify, but got: %s", err)
+	}
+	if lines := readTestAuditLogLines(t, auditLogFile); len(lines) != 102 {
+		t.Errorf("Expected 102 records, but found %d", len(lines))
+	}
+}
+
+func TestRedactArguments(t *testing.T) {
+	testCases := []struct {
+		Args     []string
+		Expected []string
+	}{
+		{
+			Args:     []string{"--networkdomain=turkey-dev", "--server-tag=monkey=1", "--region", "AU"},
+			Expected: []string{"--networkdomain=turkey-dev", "--server-tag=monkey=1", "--region", "AU"},
+		},
+		{
+			Args:     []string{"--server-password=hunter2", "--api-token", "abc123", "--force"},
+			Expected: []string{"--server-password=REDACTED", "--api-token", "REDACTED", "--force"},
+		},
+		{
+			Args:     []string{"extend", "keyvault-domain", "--by=24h"},
+			Expected: []string{"extend", "keyvault-domain", "--by=24h"},
+		},
+		{
+			Args:     []string{"--", "--secret=not-an-option"},
+			Expected: []string{"--", "--secret=not-an-option"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		actual := redactArguments(testCase.Args)
+		if !reflect.DeepEqual(actual, testCase.Expected) {
+			t.Errorf("redactArguments(%q): expected %q, but got %q", testCase.Args, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestOpenAuditLogOnlyWhenRequested(t *testing.T) {
+	directory, err := ioutil.TempDir("", "nifo-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	auditLogFile := filepath.Join(directory, "audit.log")
+
+	testCases := []struct {
+		Name    string
+		Options programOptions
+		Audited bool
+	}{
+		{
+			Name:    "no audit log",
+			Options: programOptions{Region: "AU", Datacenter: "AU9", NetworkDomain: "my-domain"},
+		},
+		{
+			Name:    "audit verify",
+			Options: programOptions{Command: "audit verify", AuditLog: auditLogFile},
+		},
+		{
+			Name:    "reclaim-ips dry run",
+			Options: programOptions{Command: "reclaim-ips", Region: "AU", AuditLog: auditLogFile, ReclaimIPs: reclaimIPsOptions{DryRun: true}},
+		},
+		{
+			Name:    "nuke",
+			Options: programOptions{Region: "AU", Datacenter: "AU9", NetworkDomain: "my-domain", AuditLog: auditLogFile},
+			Audited: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := openAuditLog(testCase.Options)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+
+			continue
+		}
+
+		_, statErr := os.Stat(auditLogFile)
+		if audited := audit != nil; audited != testCase.Audited || audited == os.IsNotExist(statErr) {
+			t.Errorf("%s: expected audited to be %t, but got %t (audit log exists: %t)", testCase.Name, testCase.Audited, audited, !os.IsNotExist(statErr))
+		}
+
+		audit.RunFinished(nil)
+		audit = nil
+		os.Remove(auditLogFile)
+	}
+}
+
+func TestAPICallIsAudited(t *testing.T) {
+	testAuditLog, directory := createTestAuditLog(t, "run1")
+	defer os.RemoveAll(directory)
+	auditLogFile := testAuditLog.file.Name()
+
+	audit = testAuditLog
+	defer func() {
+		audit = nil
+	}()
+
+	action := &actionLog{
+		Event: logEvent{Stage: "natrules", ResourceType: "natRule", ResourceID: "nat1", Action: "delete"},
+	}
+	action.BeginAPICall().End(nil)
+
+	call := beginAPICall("deploy", "vlan", "", "my-vlan")
+	call.ResourceID = "vlan1"
+	call.End(fmt.Errorf("Quota exceeded."))
+
+	// Actions that do not make API calls (e.g. running a stage) are not audited.
+	stageAction := &actionLog{
+		Event: logEvent{Stage: "natrules", Action: "run"},
+	}
+	stageAction.Complete("")
+
+	testAuditLog.RunFinished(nil)
+
+	lines := readTestAuditLogLines(t, auditLogFile)
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 records, but found %d", len(lines))
+	}
+	expected := []string{
+		`"action":"delete","resourceType":"natRule","resourceId":"nat1","response":"OK"`,
+		`"action":"deploy","resourceType":"vlan","resourceId":"vlan1","resourceName":"my-vlan","response":"Quota exceeded."`,
+		`"event":"run-finished"`,
+	}
+	for index, line := range lines {
+		if !strings.Contains(line, expected[index]) {
+			t.Errorf("Expected record %d to contain '%s', but got: %s", index+1, expected[index], line)
+		}
+	}
+}
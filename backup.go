@@ -0,0 +1,84 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// A serverBackup records a customer image cloned from a server before it was destroyed.
+type serverBackup struct {
+	ServerID   string `json:"serverId"`
+	ServerName string `json:"serverName"`
+	ImageID    string `json:"imageId"`
+	ImageName  string `json:"imageName"`
+}
+
+// Clone a (stopped) server to a customer image, and wait for the image to be ready.
+func backupServer(apiClient *compute.Client, server compute.Server, networkDomainName string, parentSpan *span) (*serverBackup, error) {
+	imageName := fmt.Sprintf("%s-%s-%s",
+		networkDomainName,
+		server.Name,
+		time.Now().UTC().Format("20060102T150405Z"),
+	)
+	imageName = unsafeFileNameCharacters.ReplaceAllString(imageName, "_")
+
+	action := beginChildAction(parentSpan, logEvent{Stage: "servers", ResourceType: "server", ResourceID: server.ID, ResourceName: server.Name, Action: "clone"},
+		"Cloning server '%s' ('%s') to customer image '%s'...",
+		server.Name,
+		server.ID,
+		imageName,
+	)
+
+	imageDescription := fmt.Sprintf("Backup of server '%s' ('%s') in network domain '%s', taken by nifo before the server was destroyed.",
+		server.Name,
+		server.ID,
+		networkDomainName,
+	)
+	call := action.BeginAPICall()
+	imageID, err := apiClient.CloneServer(server.ID, imageName, imageDescription, true)
+	call.End(err)
+	if err != nil {
+		action.Fail(err)
+
+		return nil, err
+	}
+
+	_, err = apiClient.WaitForDeploy(compute.ResourceTypeCustomerImage, imageID, 2*time.Hour)
+	if err != nil {
+		action.Fail(err)
+
+		return nil, err
+	}
+
+	action.Complete("Cloned server '%s' ('%s') to customer image '%s' ('%s').",
+		server.Name,
+		server.ID,
+		imageName,
+		imageID,
+	)
+
+	return &serverBackup{
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		ImageID:    imageID,
+		ImageName:  imageName,
+	}, nil
+}
@@ -0,0 +1,237 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// A backupSubscription records a Cloud Backup subscription that was removed from a server.
+type backupSubscription struct {
+	ServerID    string   `json:"serverId"`
+	ServerName  string   `json:"serverName"`
+	ServicePlan string   `json:"servicePlan"`
+	ClientCount int      `json:"clientCount"`
+	MonthlyCost *float64 `json:"monthlyCost,omitempty"` // nil if the cost of the service plan is unknown.
+}
+
+// The interval between checks on the status of a server's Cloud Backup service.
+const backupPollInterval = 15 * time.Second
+
+// Remove Cloud Backup from a server (if it is enabled) so that the server can be deleted.
+//
+// Running backup jobs are cancelled (or, if keepLastBackup is true, allowed to complete), backup clients are removed,
+// and then the backup service is disabled for the server.
+func removeServerBackup(apiClient *compute.Client, server compute.Server, keepLastBackup bool, parentSpan *span) (*backupSubscription, error) {
+	backupDetails, err := apiClient.GetServerBackupDetails(server.ID)
+	if err != nil {
+		return nil, err
+	}
+	if backupDetails == nil {
+		log.Printf("Cloud Backup is not enabled for server '%s' ('%s').", server.Name, server.ID)
+
+		return nil, nil
+	}
+
+	action := beginChildAction(parentSpan, logEvent{Stage: "servers", ResourceType: "server", ResourceID: server.ID, ResourceName: server.Name, Action: "remove-cloud-backup"},
+		"Removing Cloud Backup (%s plan) from server '%s' ('%s')...",
+		backupDetails.ServicePlan,
+		server.Name,
+		server.ID,
+	)
+
+	if keepLastBackup {
+		if hasRunningBackupJobs(backupDetails) {
+			logger.Printf("Waiting for running backup jobs on server '%s' ('%s') to complete...", server.Name, server.ID)
+
+			err = waitForServerBackup(apiClient, server.ID, 4*time.Hour, func(backupDetails *compute.ServerBackupDetails) bool {
+				return backupDetails == nil || !hasRunningBackupJobs(backupDetails)
+			})
+			if err != nil {
+				action.Fail(err)
+
+				return nil, err
+			}
+		}
+	} else {
+		for _, backupClient := range backupDetails.Clients {
+			if backupClient.RunningJob == nil {
+				continue
+			}
+
+			logger.Printf("Cancelling backup job '%s' for client '%s' on server '%s' ('%s')...",
+				backupClient.RunningJob.ID,
+				backupClient.Type,
+				server.Name,
+				server.ID,
+			)
+
+			call := beginAPICall("cancel-backup-jobs", "backupClient", backupClient.ID, server.Name)
+			err = apiClient.CancelServerBackupClientJobs(server.ID, backupClient.ID)
+			call.End(err)
+			if err != nil {
+				action.Fail(err)
+
+				return nil, err
+			}
+		}
+
+		err = waitForServerBackup(apiClient, server.ID, 15*time.Minute, func(backupDetails *compute.ServerBackupDetails) bool {
+			return backupDetails == nil || !hasRunningBackupJobs(backupDetails)
+		})
+		if err != nil {
+			action.Fail(err)
+
+			return nil, err
+		}
+	}
+
+	for _, backupClient := range backupDetails.Clients {
+		logger.Printf("Removing backup client '%s' ('%s') from server '%s' ('%s')...",
+			backupClient.Type,
+			backupClient.ID,
+			server.Name,
+			server.ID,
+		)
+
+		call := beginAPICall("delete", "backupClient", backupClient.ID, server.Name)
+		err = apiClient.RemoveServerBackupClient(server.ID, backupClient.ID)
+		call.End(err)
+		if err != nil {
+			action.Fail(err)
+
+			return nil, err
+		}
+	}
+
+	logger.Printf("Disabling Cloud Backup for server '%s' ('%s')...", server.Name, server.ID)
+
+	call := beginAPICall("disable-backup", "server", server.ID, server.Name)
+	err = apiClient.DisableServerBackup(server.ID)
+	call.End(err)
+	if err != nil {
+		action.Fail(err)
+
+		return nil, err
+	}
+
+	err = waitForServerBackup(apiClient, server.ID, 15*time.Minute, func(backupDetails *compute.ServerBackupDetails) bool {
+		return backupDetails == nil
+	})
+	if err != nil {
+		action.Fail(err)
+
+		return nil, err
+	}
+
+	action.Complete("Removed Cloud Backup (%s plan) from server '%s' ('%s').",
+		backupDetails.ServicePlan,
+		server.Name,
+		server.ID,
+	)
+
+	return &backupSubscription{
+		ServerID:    server.ID,
+		ServerName:  server.Name,
+		ServicePlan: backupDetails.ServicePlan,
+		ClientCount: len(backupDetails.Clients),
+	}, nil
+}
+
+func hasRunningBackupJobs(backupDetails *compute.ServerBackupDetails) bool {
+	for _, backupClient := range backupDetails.Clients {
+		if backupClient.RunningJob != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Poll a server's Cloud Backup details until the specified condition is met.
+func waitForServerBackup(apiClient *compute.Client, serverID string, timeout time.Duration, condition func(backupDetails *compute.ServerBackupDetails) bool) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		backupDetails, err := apiClient.GetServerBackupDetails(serverID)
+		if err != nil {
+			return err
+		}
+		if condition(backupDetails) {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out after %s waiting for Cloud Backup on server '%s'.", timeout, serverID)
+		}
+
+		time.Sleep(backupPollInterval)
+	}
+}
+
+// Total the monthly cost of the specified Cloud Backup subscriptions (and count those whose cost is unknown).
+func totalBackupSubscriptionCost(subscriptions []backupSubscription) (totalCost float64, unknownCost int) {
+	for _, subscription := range subscriptions {
+		if subscription.MonthlyCost == nil {
+			unknownCost++
+
+			continue
+		}
+
+		totalCost += *subscription.MonthlyCost
+	}
+
+	return
+}
+
+// Display the Cloud Backup subscriptions removed during the run, and the estimated monthly cost saved.
+func showBackupSubscriptionSummary(subscriptions []backupSubscription) {
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.MonthlyCost == nil {
+			fmt.Fprintf(console, "Removed Cloud Backup (%s plan, %d client(s)) from server '%s' ('%s').\n",
+				subscription.ServicePlan,
+				subscription.ClientCount,
+				subscription.ServerName,
+				subscription.ServerID,
+			)
+
+			continue
+		}
+
+		fmt.Fprintf(console, "Removed Cloud Backup (%s plan, %d client(s)) from server '%s' ('%s'), saving %.2f per month.\n",
+			subscription.ServicePlan,
+			subscription.ClientCount,
+			subscription.ServerName,
+			subscription.ServerID,
+			*subscription.MonthlyCost,
+		)
+	}
+
+	totalCost, unknownCost := totalBackupSubscriptionCost(subscriptions)
+	fmt.Fprintf(console, "Removed %d Cloud Backup subscription(s), saving an estimated %.2f per month", len(subscriptions), totalCost)
+	if unknownCost > 0 {
+		fmt.Fprintf(console, " (cost unknown for %d subscription(s); add its service plan to the rate card's backupPlans)", unknownCost)
+	}
+	fmt.Fprintln(console, ".")
+}
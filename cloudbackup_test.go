@@ -0,0 +1,59 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestHasRunningBackupJobs(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Clients  []compute.ServerBackupClientDetail
+		Expected bool
+	}{
+		{
+			Name:     "no clients",
+			Expected: false,
+		},
+		{
+			Name: "idle clients",
+			Clients: []compute.ServerBackupClientDetail{
+				{ID: "client1", Type: "FA.Linux"},
+				{ID: "client2", Type: "MySQL"},
+			},
+			Expected: false,
+		},
+		{
+			Name: "one client running a job",
+			Clients: []compute.ServerBackupClientDetail{
+				{ID: "client1", Type: "FA.Linux"},
+				{ID: "client2", Type: "MySQL", RunningJob: &compute.ServerBackupClientRunningJob{ID: "job1", Status: "Running"}},
+			},
+			Expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		backupDetails := &compute.ServerBackupDetails{ServicePlan: "Essentials", Clients: testCase.Clients}
+		if actual := hasRunningBackupJobs(backupDetails); actual != testCase.Expected {
+			t.Errorf("%s: expected %t, but got %t", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+	"gopkg.in/yaml.v2"
+)
+
+// The name of the rate card entry used for datacenters that do not have their own.
+const defaultRateCardEntry = "default"
+
+// A rateCardDocument is the contents of a rate card file.
+type rateCardDocument struct {
+	Currency    string               `yaml:"currency"`
+	Datacenters map[string]*rateCard `yaml:"datacenters"`
+}
+
+// A rateCard holds the monthly rates for resources in a datacenter.
+type rateCard struct {
+	Currency        string             `yaml:"-"`
+	CPU             float64            `yaml:"cpu"`             // Per vCPU.
+	RAMGB           float64            `yaml:"ramGB"`           // Per GB of RAM.
+	Storage         map[string]float64 `yaml:"storage"`         // Per GB of disk, by disk speed (e.g. STANDARD).
+	PublicIPBlock   float64            `yaml:"publicIPBlock"`   // Per public IP block.
+	VirtualListener float64            `yaml:"virtualListener"` // Per virtual listener (load balancer).
+	BackupPlans     map[string]float64 `yaml:"backupPlans"`     // Per server with Cloud Backup, by service plan (keyed in lower case).
+}
+
+// BackupPlanCost looks up the monthly cost of a Cloud Backup service plan (returns nil if it is unknown).
+func (card *rateCard) BackupPlanCost(servicePlan string) *float64 {
+	if card == nil {
+		return nil
+	}
+
+	cost, ok := card.BackupPlans[strings.ToLower(servicePlan)]
+	if !ok {
+		return nil
+	}
+
+	return &cost
+}
+
+// A costEstimate is the estimated monthly cost of the resources in a plan (i.e. how much nuking them will save).
+type costEstimate struct {
+	Currency       string   `json:"currency,omitempty"`
+	Servers        float64  `json:"servers"`
+	PublicIPBlocks float64  `json:"publicIpBlocks"`
+	LoadBalancers  float64  `json:"loadBalancers"`
+	CloudBackup    float64  `json:"cloudBackup"`
+	Total          float64  `json:"total"`
+	Unpriced       []string `json:"unpriced,omitempty"`
+}
+
+// String describes the estimate (e.g. "USD 135.95 per month (servers 100.00, public IP blocks 20.00, load balancers 3.45,
+// Cloud Backup 12.50)").
+func (estimate *costEstimate) String() string {
+	description := fmt.Sprintf("%s per month (servers %.2f, public IP blocks %.2f, load balancers %.2f, Cloud Backup %.2f)",
+		formatCost(estimate.Currency, estimate.Total),
+		estimate.Servers,
+		estimate.PublicIPBlocks,
+		estimate.LoadBalancers,
+		estimate.CloudBackup,
+	)
+	if len(estimate.Unpriced) > 0 {
+		description += fmt.Sprintf("; not priced: %s", strings.Join(estimate.Unpriced, ", "))
+	}
+
+	return description
+}
+
+// Load the rates for a datacenter from a rate card file (falling back to the rate card's "default" entry).
+//
+// A rate card is a YAML file like:
+//
+//	currency: USD
+//	datacenters:
+//	  AU9:
+//	    cpu: 25.00
+//	    ramGB: 12.50
+//	    storage:
+//	      STANDARD: 0.25
+//	      HIGHPERFORMANCE: 0.40
+//	      ECONOMY: 0.10
+//	    publicIPBlock: 20.00
+//	    virtualListener: 35.00
+//	    backupPlans:
+//	      Essentials: 12.50
+//	      Advanced: 20.00
+//	      Enterprise: 30.00
+//	  default:
+//	    ...
+func loadRateCard(rateCardFile string, datacenterID string) (*rateCard, error) {
+	data, err := ioutil.ReadFile(rateCardFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var document rateCardDocument
+	err = yaml.UnmarshalStrict(data, &document)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid rate card '%s' (%s).", rateCardFile, err)
+	}
+	if document.Datacenters == nil {
+		return nil, fmt.Errorf("Invalid rate card '%s' (must contain a 'datacenters' mapping).", rateCardFile)
+	}
+
+	entryName := datacenterID
+	card, ok := document.Datacenters[entryName]
+	if !ok {
+		entryName = defaultRateCardEntry
+		card, ok = document.Datacenters[entryName]
+	}
+	if !ok {
+		return nil, fmt.Errorf("Rate card '%s' has no rates for datacenter '%s' (and no '%s' rates).", rateCardFile, datacenterID, defaultRateCardEntry)
+	}
+	if card == nil {
+		return nil, fmt.Errorf("Invalid rate card '%s' (the '%s' entry must be a mapping of rates).", rateCardFile, entryName)
+	}
+	card.Currency = document.Currency
+
+	rates := map[string]float64{
+		"cpu":             card.CPU,
+		"ramGB":           card.RAMGB,
+		"publicIPBlock":   card.PublicIPBlock,
+		"virtualListener": card.VirtualListener,
+	}
+	storageRates := card.Storage
+	card.Storage = make(map[string]float64)
+	for speed, rate := range storageRates {
+		rates["storage."+speed] = rate
+		card.Storage[strings.ToUpper(speed)] = rate
+	}
+	backupPlanRates := card.BackupPlans
+	card.BackupPlans = make(map[string]float64)
+	for servicePlan, rate := range backupPlanRates {
+		rates["backupPlans."+servicePlan] = rate
+		card.BackupPlans[strings.ToLower(servicePlan)] = rate
+	}
+	for name, rate := range rates {
+		if rate < 0 {
+			return nil, fmt.Errorf("Invalid rate card '%s' (rate '%s' must be a non-negative number).", rateCardFile, name)
+		}
+	}
+
+	return card, nil
+}
+
+// Estimate the monthly cost of the resources that the selected stages will destroy.
+func estimateMonthlyCost(apiClient *compute.Client, networkDomainID string, stages []nukeStage, card *rateCard, options programOptions) (*costEstimate, error) {
+	estimate := &costEstimate{
+		Currency: card.Currency,
+	}
+
+	if hasStage(stages, "servers") {
+		servers, err := selectServers(apiClient, networkDomainID, options)
+		if err != nil {
+			return nil, err
+		}
+
+		unpricedSpeeds := make(map[string]bool)
+		unpricedBackupPlans := make(map[string]bool)
+		for _, server := range servers {
+			estimate.Servers += float64(server.CPU.Count)*card.CPU + float64(server.MemoryGB)*card.RAMGB
+			for _, disk := range server.Disks {
+				storageRate, ok := card.Storage[strings.ToUpper(disk.Speed)]
+				if !ok {
+					unpricedSpeeds[disk.Speed] = true
+
+					continue
+				}
+				estimate.Servers += float64(disk.SizeGB) * storageRate
+			}
+
+			backupDetails, err := apiClient.GetServerBackupDetails(server.ID)
+			if err != nil {
+				return nil, err
+			}
+			if backupDetails == nil {
+				continue
+			}
+			backupPlanCost := card.BackupPlanCost(backupDetails.ServicePlan)
+			if backupPlanCost == nil {
+				unpricedBackupPlans[backupDetails.ServicePlan] = true
+
+				continue
+			}
+			estimate.CloudBackup += *backupPlanCost
+		}
+		for speed := range unpricedSpeeds {
+			estimate.Unpriced = append(estimate.Unpriced, fmt.Sprintf("%s storage", speed))
+		}
+		for servicePlan := range unpricedBackupPlans {
+			estimate.Unpriced = append(estimate.Unpriced, fmt.Sprintf("%s Cloud Backup plan", servicePlan))
+		}
+		sort.Strings(estimate.Unpriced)
+	}
+
+	if hasStage(stages, "publicips") {
+		publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomainID)
+		if err != nil {
+			return nil, err
+		}
+		estimate.PublicIPBlocks = float64(len(publicIPBlocks)) * card.PublicIPBlock
+	}
+
+	if hasStage(stages, "virtuallisteners") {
+		virtualListeners, err := listVirtualListeners(apiClient, networkDomainID)
+		if err != nil {
+			return nil, err
+		}
+		estimate.LoadBalancers = float64(len(virtualListeners)) * card.VirtualListener
+	}
+
+	estimate.Total = estimate.Servers + estimate.PublicIPBlocks + estimate.LoadBalancers + estimate.CloudBackup
+
+	return estimate, nil
+}
+
+// Format a cost (e.g. "USD 123.45").
+func formatCost(currency string, cost float64) string {
+	if currency == "" {
+		return fmt.Sprintf("%.2f", cost)
+	}
+
+	return fmt.Sprintf("%s %.2f", currency, cost)
+}
@@ -0,0 +1,153 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadRateCard(t *testing.T) {
+	rateCardFile := writeTestRateCard(t, `currency: USD
+datacenters:
+  AU9:
+    cpu: 25.00
+    ramGB: 12.50
+    storage:
+      STANDARD: 0.25
+    publicIPBlock: 20
+    virtualListener: 35.00
+    backupPlans:
+      Essentials: 12.50 # Per server.
+  default:
+    cpu: 30.00
+`)
+
+	card, err := loadRateCard(rateCardFile, "AU9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.Currency != "USD" || card.CPU != 25 || card.RAMGB != 12.5 || card.Storage["STANDARD"] != 0.25 || card.PublicIPBlock != 20 || card.VirtualListener != 35 {
+		t.Errorf("Unexpected rates for AU9: %+v", card)
+	}
+	if cost := card.BackupPlanCost("ESSENTIALS"); cost == nil || *cost != 12.5 {
+		t.Errorf("Expected Essentials Cloud Backup plan to cost 12.50, but got %v", cost)
+	}
+	if cost := card.BackupPlanCost("Enterprise"); cost != nil {
+		t.Errorf("Expected Enterprise Cloud Backup plan to be unpriced, but got %.2f", *cost)
+	}
+
+	card, err = loadRateCard(rateCardFile, "NA9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card.CPU != 30 {
+		t.Errorf("Expected NA9 to use the default rates, but got %+v", card)
+	}
+
+	var noCard *rateCard
+	if cost := noCard.BackupPlanCost("Essentials"); cost != nil {
+		t.Errorf("Expected no Cloud Backup plan cost without a rate card, but got %.2f", *cost)
+	}
+}
+
+func TestLoadRateCardErrors(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		RateCard string
+		Error    string
+	}{
+		{
+			Name:     "mis-indented rates",
+			RateCard: "datacenters:\n  AU9:\n      cpu: 25.00\n    ramGB: 12.50\n",
+			Error:    "did not find expected key",
+		},
+		{
+			Name:     "unknown rate",
+			RateCard: "datacenters:\n  AU9:\n    gpu: 25.00\n",
+			Error:    "field gpu not found",
+		},
+		{
+			Name:     "negative rate",
+			RateCard: "datacenters:\n  AU9:\n    cpu: -1\n",
+			Error:    "rate 'cpu' must be a non-negative number",
+		},
+		{
+			Name:     "non-numeric backup plan rate",
+			RateCard: "datacenters:\n  AU9:\n    backupPlans:\n      Essentials: cheap\n",
+			Error:    "cannot unmarshal !!str `cheap` into float64",
+		},
+		{
+			Name:     "negative backup plan rate",
+			RateCard: "datacenters:\n  AU9:\n    backupPlans:\n      Essentials: -12.50\n",
+			Error:    "rate 'backupPlans.Essentials' must be a non-negative number",
+		},
+		{
+			Name:     "no datacenters",
+			RateCard: "currency: USD\n",
+			Error:    "must contain a 'datacenters' mapping",
+		},
+		{
+			Name:     "empty datacenter entry",
+			RateCard: "datacenters:\n  AU9:\n  default:\n    cpu: 25.00\n",
+			Error:    "the 'AU9' entry must be a mapping of rates",
+		},
+		{
+			Name:     "empty default entry",
+			RateCard: "datacenters:\n  NA9:\n    cpu: 25.00\n  default:\n",
+			Error:    "the 'default' entry must be a mapping of rates",
+		},
+		{
+			Name:     "scalar datacenter entry",
+			RateCard: "datacenters:\n  AU9: 25.00\n  default:\n    cpu: 25.00\n",
+			Error:    "cannot unmarshal !!float `25.00` into main.rateCard",
+		},
+		{
+			Name:     "no rates for datacenter",
+			RateCard: "datacenters:\n  NA9:\n    cpu: 25.00\n",
+			Error:    "no rates for datacenter 'AU9'",
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := loadRateCard(writeTestRateCard(t, testCase.RateCard), "AU9")
+		if err == nil || !strings.Contains(err.Error(), testCase.Error) {
+			t.Errorf("%s: expected error containing '%s', but got: %v", testCase.Name, testCase.Error, err)
+		}
+	}
+}
+
+func writeTestRateCard(t *testing.T, content string) string {
+	directory, err := ioutil.TempDir("", "nifo-rate-card")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(directory)
+	})
+
+	rateCardFile := filepath.Join(directory, "rates.yml")
+	err = ioutil.WriteFile(rateCardFile, []byte(content), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rateCardFile
+}
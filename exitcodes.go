@@ -0,0 +1,197 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// Exit codes returned by the program.
+//
+// These are part of nifo's interface (automation relies on them), so existing codes must never be changed or reused.
+const (
+	exitCodeSuccess               = 0
+	exitCodeError                 = 1 // An error that does not fall into one of the other categories.
+	exitCodeNotConfirmed          = 2 // The user did not confirm that they wanted to proceed.
+	exitCodeBadOptions            = 3 // Invalid command-line options.
+	exitCodeAuthenticationFailed  = 4 // Credentials are missing, or were rejected by CloudControl.
+	exitCodeNetworkDomainNotFound = 5 // The target network domain does not exist.
+	exitCodeProtectionViolation   = 6 // A safety check (e.g. external references or stage dependencies) prevented the nuke.
+	exitCodePartialFailure        = 7 // The nuke started but failed, so some resources remain.
+	exitCodeTimeout               = 8 // The nuke started but timed out waiting for CloudControl, so some resources may remain.
+)
+
+// badOptionsError is returned when the program's options are invalid.
+type badOptionsError struct {
+	Err error
+}
+
+func (err *badOptionsError) Error() string {
+	return err.Err.Error()
+}
+
+// authenticationError is returned when CloudControl credentials are missing or rejected.
+type authenticationError struct {
+	Err error
+}
+
+func (err *authenticationError) Error() string {
+	return err.Err.Error()
+}
+
+// networkDomainNotFoundError is returned when the target network domain does not exist.
+type networkDomainNotFoundError struct {
+	Name         string
+	DatacenterID string
+}
+
+func (err *networkDomainNotFoundError) Error() string {
+	return fmt.Sprintf("Unable to find network domain '%s' in datacenter '%s'.", err.Name, err.DatacenterID)
+}
+
+// protectionError is returned when a safety check prevents a nuke from proceeding.
+type protectionError struct {
+	Message string
+}
+
+func (err *protectionError) Error() string {
+	return err.Message
+}
+
+// partialFailureError is returned when a nuke fails after it has started deleting resources.
+type partialFailureError struct {
+	NetworkDomainID string
+	Err             error
+}
+
+func (err *partialFailureError) Error() string {
+	return err.Err.Error()
+}
+
+// serverFailuresError is returned when one or more servers could not be destroyed.
+//
+// It is safe to add errors from multiple goroutines.
+type serverFailuresError struct {
+	lock            sync.Mutex
+	NetworkDomainID string
+	Errs            []error
+}
+
+// Add the error for a server that could not be destroyed.
+func (err *serverFailuresError) Add(serverErr error) {
+	err.lock.Lock()
+	defer err.lock.Unlock()
+
+	err.Errs = append(err.Errs, serverErr)
+}
+
+func (err *serverFailuresError) Error() string {
+	return fmt.Sprintf("Destroy failed for %d server(s) in network domain '%s' (first error: %s).", len(err.Errs), err.NetworkDomainID, err.Errs[0])
+}
+
+// Determine whether an error is the result of timing out while waiting for CloudControl.
+//
+// For servers, this is only the case if every server that could not be destroyed timed out.
+func isTimeoutError(err error) bool {
+	switch typedErr := err.(type) {
+	case *compute.OperationTimeoutError:
+		return true
+	case *partialFailureError:
+		return isTimeoutError(typedErr.Err)
+	case *serverFailuresError:
+		for _, serverErr := range typedErr.Errs {
+			if !isTimeoutError(serverErr) {
+				return false
+			}
+		}
+
+		return len(typedErr.Errs) > 0
+	default:
+		return false
+	}
+}
+
+// Determine the exit code for an error returned by a command.
+func exitCodeForError(err error) int {
+	if err == nil {
+		return exitCodeSuccess
+	}
+
+	if err == errNotConfirmed {
+		return exitCodeNotConfirmed
+	}
+	if err == errExternalReferences {
+		return exitCodeProtectionViolation
+	}
+
+	switch typedErr := err.(type) {
+	case *badOptionsError:
+		return exitCodeBadOptions
+	case *authenticationError:
+		return exitCodeAuthenticationFailed
+	case *networkDomainNotFoundError:
+		return exitCodeNetworkDomainNotFound
+	case *protectionError:
+		return exitCodeProtectionViolation
+	case *partialFailureError:
+		if isTimeoutError(typedErr) {
+			return exitCodeTimeout
+		}
+
+		return exitCodePartialFailure
+	case *compute.OperationTimeoutError:
+		return exitCodeTimeout
+	default:
+		return exitCodeError
+	}
+}
+
+// Matches the HTTP status codes (or descriptions) that CloudControl returns when credentials are rejected.
+var authenticationFailureStatus = regexp.MustCompile(`(?i)\b(401|403|unauthorized|forbidden|invalid credentials)\b`)
+
+// Verify that CloudControl accepts the client's credentials.
+//
+// Only a rejection of the credentials (401 / 403) is treated as an authentication failure; other errors (e.g. DNS, TLS, or
+// network errors) are returned as-is.
+func verifyCredentials(apiClient *compute.Client) error {
+	account, err := apiClient.GetAccount()
+	if err != nil {
+		if isAuthenticationFailure(err) {
+			return &authenticationError{
+				Err: fmt.Errorf("Unable to authenticate to CloudControl (%s).", err),
+			}
+		}
+
+		return fmt.Errorf("Unable to connect to CloudControl (%s).", err)
+	}
+	if account == nil {
+		return &authenticationError{
+			Err: fmt.Errorf("Unable to authenticate to CloudControl (check the MCP_USER and MCP_PASSWORD environment variables)."),
+		}
+	}
+
+	return nil
+}
+
+// Determine whether an error from CloudControl indicates that the client's credentials were rejected.
+func isAuthenticationFailure(err error) bool {
+	return authenticationFailureStatus.MatchString(err.Error())
+}
@@ -0,0 +1,91 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestExitCodeForError(t *testing.T) {
+	timeout := &compute.OperationTimeoutError{}
+	otherErr := errors.New("Something went wrong.")
+
+	testCases := []struct {
+		Name     string
+		Err      error
+		Expected int
+	}{
+		{"success", nil, exitCodeSuccess},
+		{"generic error", otherErr, exitCodeError},
+		{"not confirmed", errNotConfirmed, exitCodeNotConfirmed},
+		{"bad options", &badOptionsError{Err: otherErr}, exitCodeBadOptions},
+		{"authentication failed", &authenticationError{Err: otherErr}, exitCodeAuthenticationFailed},
+		{"network domain not found", &networkDomainNotFoundError{Name: "my-domain", DatacenterID: "AU9"}, exitCodeNetworkDomainNotFound},
+		{"external references", errExternalReferences, exitCodeProtectionViolation},
+		{"protection violation", &protectionError{Message: "Stage dependency."}, exitCodeProtectionViolation},
+		{"partial failure", &partialFailureError{Err: otherErr}, exitCodePartialFailure},
+		{"timeout", timeout, exitCodeTimeout},
+		{"partial failure due to timeout", &partialFailureError{Err: timeout}, exitCodeTimeout},
+		{
+			"server timeouts",
+			&partialFailureError{Err: &serverFailuresError{Errs: []error{timeout, timeout}}},
+			exitCodeTimeout,
+		},
+		{
+			"server timeout and failure",
+			&partialFailureError{Err: &serverFailuresError{Errs: []error{timeout, otherErr}}},
+			exitCodePartialFailure,
+		},
+		{
+			"server failures",
+			&partialFailureError{Err: &serverFailuresError{Errs: []error{otherErr}}},
+			exitCodePartialFailure,
+		},
+	}
+
+	for _, testCase := range testCases {
+		actual := exitCodeForError(testCase.Err)
+		if actual != testCase.Expected {
+			t.Errorf("%s: expected exit code %d, but got %d", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestIsAuthenticationFailure(t *testing.T) {
+	testCases := []struct {
+		Err      error
+		Expected bool
+	}{
+		{errors.New("Request failed with status code 401 (Unauthorized)."), true},
+		{errors.New("Request failed with status code 403."), true},
+		{errors.New("Cannot connect to compute API (invalid credentials)."), true},
+		{errors.New("dial tcp: lookup api-au.dimensiondata.com: no such host"), false},
+		{errors.New("x509: certificate signed by unknown authority"), false},
+		{errors.New("Request failed with status code 500."), false},
+		{errors.New("Request failed with status code 4010."), false},
+	}
+
+	for _, testCase := range testCases {
+		actual := isAuthenticationFailure(testCase.Err)
+		if actual != testCase.Expected {
+			t.Errorf("isAuthenticationFailure('%s'): expected %t, but got %t", testCase.Err, testCase.Expected, actual)
+		}
+	}
+}
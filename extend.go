@@ -0,0 +1,122 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// extendOptions represents the options for the "extend" command.
+type extendOptions struct {
+	By   time.Duration `long:"by" required:"yes" description:"How much longer the network domain should live (e.g. 48h)."`
+	Args struct {
+		NetworkDomain string `positional-arg-name:"networkdomain" description:"The name of the network domain whose expiry should be extended."`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// Extend the lifetime of a network domain that has an expiry (or time-to-live) tag.
+//
+// The new expiry is written to the expiry tag; any time-to-live tag is removed (since it would otherwise still apply).
+func extend(apiClient *compute.Client, options programOptions) error {
+	networkDomainName := options.Extend.Args.NetworkDomain
+	networkDomain, err := apiClient.GetNetworkDomainByName(networkDomainName, options.Datacenter)
+	if err != nil {
+		return err
+	}
+	if networkDomain == nil {
+		return &networkDomainNotFoundError{
+			Name:         networkDomainName,
+			DatacenterID: options.Datacenter,
+		}
+	}
+
+	tags, err := listAssetTags(apiClient, networkDomain.ID, compute.AssetTypeNetworkDomain)
+	if err != nil {
+		return err
+	}
+
+	expiryTag, removeTagNames, err := extendedExpiryTags(*networkDomain, tags, options.Extend.By, time.Now())
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Extending expiry of network domain '%s' ('%s') to %s...",
+		networkDomain.Name,
+		networkDomain.ID,
+		expiryTag.Value,
+	)
+
+	call := beginAPICall("apply-tags", "networkDomain", networkDomain.ID, networkDomain.Name)
+	err = apiClient.ApplyAssetTags(networkDomain.ID, compute.AssetTypeNetworkDomain, expiryTag)
+	call.End(err)
+	if err != nil {
+		return err
+	}
+
+	if len(removeTagNames) > 0 {
+		call := beginAPICall("remove-tags", "networkDomain", networkDomain.ID, networkDomain.Name)
+		err = apiClient.RemoveAssetTags(networkDomain.ID, compute.AssetTypeNetworkDomain, removeTagNames...)
+		call.End(err)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.Printf("Extended expiry of network domain '%s' ('%s') to %s.",
+		networkDomain.Name,
+		networkDomain.ID,
+		expiryTag.Value,
+	)
+
+	return nil
+}
+
+// Determine the tags that extend a network domain's lifetime: the expiry tag to apply, and the names of the tags to
+// remove (the time-to-live tag, if any, since it would otherwise still apply).
+func extendedExpiryTags(networkDomain compute.NetworkDomain, tags []compute.TagDetail, by time.Duration, now time.Time) (expiryTag compute.Tag, removeTagNames []string, err error) {
+	expiry, hasExpiry, err := networkDomainExpiry(networkDomain, tags)
+	if err != nil {
+		return
+	}
+	if !hasExpiry {
+		err = fmt.Errorf("Network domain '%s' does not have a '%s' or '%s' tag.", networkDomain.Name, expiresTagName, ttlTagName)
+
+		return
+	}
+
+	// An already-expired network domain (that the janitor has not yet nuked) is extended from now.
+	if expiry.Before(now) {
+		expiry = now
+	}
+
+	expiryTag = compute.Tag{
+		Name:  expiresTagName,
+		Value: expiry.Add(by).UTC().Format(time.RFC3339),
+	}
+	for _, tag := range tags {
+		if tag.Name == ttlTagName {
+			removeTagNames = append(removeTagNames, ttlTagName)
+
+			break
+		}
+	}
+
+	return
+}
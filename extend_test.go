@@ -0,0 +1,101 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestExtendedExpiryTags(t *testing.T) {
+	now := time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC)
+	networkDomain := compute.NetworkDomain{
+		ID:         "my-domain-id",
+		Name:       "my-domain",
+		CreateTime: "2016-11-01T00:00:00Z",
+	}
+
+	testCases := []struct {
+		Name     string
+		Tags     []compute.TagDetail
+		Expected string
+		Removed  string
+		Error    bool
+	}{
+		{
+			Name:     "expiry",
+			Tags:     []compute.TagDetail{{Name: expiresTagName, Value: "2016-11-02T00:00:00Z"}},
+			Expected: "2016-11-04T00:00:00Z",
+		},
+		{
+			Name:     "time-to-live is replaced by expiry",
+			Tags:     []compute.TagDetail{{Name: ttlTagName, Value: "24h"}},
+			Expected: "2016-11-04T00:00:00Z",
+			Removed:  ttlTagName,
+		},
+		{
+			Name: "earliest of expiry and time-to-live",
+			Tags: []compute.TagDetail{
+				{Name: expiresTagName, Value: "2016-11-03T00:00:00Z"},
+				{Name: ttlTagName, Value: "24h"},
+			},
+			Expected: "2016-11-04T00:00:00Z",
+			Removed:  ttlTagName,
+		},
+		{
+			Name:     "already expired is extended from now",
+			Tags:     []compute.TagDetail{{Name: ttlTagName, Value: "1h"}},
+			Expected: "2016-11-03T12:00:00Z",
+			Removed:  ttlTagName,
+		},
+		{
+			Name:  "no expiry",
+			Tags:  []compute.TagDetail{{Name: "owner", Value: "me"}},
+			Error: true,
+		},
+		{
+			Name:  "invalid time-to-live",
+			Tags:  []compute.TagDetail{{Name: ttlTagName, Value: "soon"}},
+			Error: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		expiryTag, removeTagNames, err := extendedExpiryTags(networkDomain, testCase.Tags, 48*time.Hour, now)
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("%s: expected an error", testCase.Name)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+
+			continue
+		}
+		if expiryTag.Name != expiresTagName || expiryTag.Value != testCase.Expected {
+			t.Errorf("%s: expected tag %s=%s, but got %s=%s", testCase.Name, expiresTagName, testCase.Expected, expiryTag.Name, expiryTag.Value)
+		}
+		if removed := strings.Join(removeTagNames, ","); removed != testCase.Removed {
+			t.Errorf("%s: expected to remove tags '%s', but got '%s'", testCase.Name, testCase.Removed, removed)
+		}
+	}
+}
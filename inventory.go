@@ -0,0 +1,598 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+	"gopkg.in/yaml.v2"
+)
+
+// An inventory of a network domain's contents, captured before it is destroyed.
+type inventory struct {
+	CapturedAt     string                   `json:"capturedAt" yaml:"capturedAt"`
+	Region         string                   `json:"region" yaml:"region"`
+	NetworkDomain  inventoryNetworkDomain   `json:"networkDomain" yaml:"networkDomain"`
+	Servers        []inventoryServer        `json:"servers" yaml:"servers"`
+	VLANs          []inventoryVLAN          `json:"vlans" yaml:"vlans"`
+	NATRules       []inventoryNATRule       `json:"natRules" yaml:"natRules"`
+	FirewallRules  []inventoryFirewallRule  `json:"firewallRules" yaml:"firewallRules"`
+	PublicIPBlocks []inventoryPublicIPBlock `json:"publicIPBlocks" yaml:"publicIPBlocks"`
+	LoadBalancer   inventoryLoadBalancer    `json:"loadBalancer" yaml:"loadBalancer"`
+
+	AntiAffinityRules []inventoryAntiAffinityRule `json:"antiAffinityRules" yaml:"antiAffinityRules"`
+	StaticRoutes      []inventoryStaticRoute      `json:"staticRoutes" yaml:"staticRoutes"`
+}
+
+type inventoryNetworkDomain struct {
+	ID             string `json:"id" yaml:"id"`
+	Name           string `json:"name" yaml:"name"`
+	Description    string `json:"description,omitempty" yaml:"description,omitempty"`
+	Type           string `json:"type" yaml:"type"`
+	DatacenterID   string `json:"datacenterId" yaml:"datacenterId"`
+	NatIPv4Address string `json:"natIPv4Address,omitempty" yaml:"natIPv4Address,omitempty"`
+	CreateTime     string `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+}
+
+type inventoryServer struct {
+	ID                string                    `json:"id" yaml:"id"`
+	Name              string                    `json:"name" yaml:"name"`
+	Description       string                    `json:"description,omitempty" yaml:"description,omitempty"`
+	SourceImageID     string                    `json:"sourceImageId" yaml:"sourceImageId"`
+	OperatingSystem   string                    `json:"operatingSystem" yaml:"operatingSystem"`
+	CPUCount          int                       `json:"cpuCount" yaml:"cpuCount"`
+	CPUSpeed          string                    `json:"cpuSpeed" yaml:"cpuSpeed"`
+	CPUCoresPerSocket int                       `json:"cpuCoresPerSocket" yaml:"cpuCoresPerSocket"`
+	MemoryGB          int                       `json:"memoryGB" yaml:"memoryGB"`
+	Started           bool                      `json:"started" yaml:"started"`
+	Disks             []inventoryServerDisk     `json:"disks" yaml:"disks"`
+	NetworkAdapters   []inventoryNetworkAdapter `json:"networkAdapters" yaml:"networkAdapters"`
+	Tags              []inventoryTag            `json:"tags" yaml:"tags"`
+	CreateTime        string                    `json:"createTime,omitempty" yaml:"createTime,omitempty"`
+}
+
+type inventoryServerDisk struct {
+	ID         string `json:"id" yaml:"id"`
+	SCSIUnitID int    `json:"scsiUnitId" yaml:"scsiUnitId"`
+	SizeGB     int    `json:"sizeGB" yaml:"sizeGB"`
+	Speed      string `json:"speed" yaml:"speed"`
+}
+
+type inventoryNetworkAdapter struct {
+	ID                 string `json:"id" yaml:"id"`
+	Primary            bool   `json:"primary" yaml:"primary"`
+	VLANID             string `json:"vlanId" yaml:"vlanId"`
+	VLANName           string `json:"vlanName" yaml:"vlanName"`
+	PrivateIPv4Address string `json:"privateIPv4Address,omitempty" yaml:"privateIPv4Address,omitempty"`
+	PrivateIPv6Address string `json:"privateIPv6Address,omitempty" yaml:"privateIPv6Address,omitempty"`
+	AdapterType        string `json:"adapterType,omitempty" yaml:"adapterType,omitempty"`
+}
+
+type inventoryTag struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+type inventoryAntiAffinityRule struct {
+	ID      string                     `json:"id" yaml:"id"`
+	Servers []inventoryServerReference `json:"servers" yaml:"servers"`
+}
+
+type inventoryServerReference struct {
+	ID   string `json:"id" yaml:"id"`
+	Name string `json:"name" yaml:"name"`
+}
+
+type inventoryVLAN struct {
+	ID                 string `json:"id" yaml:"id"`
+	Name               string `json:"name" yaml:"name"`
+	Description        string `json:"description,omitempty" yaml:"description,omitempty"`
+	IPv4BaseAddress    string `json:"ipv4BaseAddress" yaml:"ipv4BaseAddress"`
+	IPv4PrefixSize     int    `json:"ipv4PrefixSize" yaml:"ipv4PrefixSize"`
+	IPv4GatewayAddress string `json:"ipv4GatewayAddress" yaml:"ipv4GatewayAddress"`
+	IPv6BaseAddress    string `json:"ipv6BaseAddress" yaml:"ipv6BaseAddress"`
+	IPv6PrefixSize     int    `json:"ipv6PrefixSize" yaml:"ipv6PrefixSize"`
+	IPv6GatewayAddress string `json:"ipv6GatewayAddress" yaml:"ipv6GatewayAddress"`
+
+	ReservedIPv4Addresses []string `json:"reservedIPv4Addresses" yaml:"reservedIPv4Addresses"`
+	ReservedIPv6Addresses []string `json:"reservedIPv6Addresses" yaml:"reservedIPv6Addresses"`
+}
+
+type inventoryStaticRoute struct {
+	ID                        string `json:"id" yaml:"id"`
+	Name                      string `json:"name" yaml:"name"`
+	IPVersion                 string `json:"ipVersion" yaml:"ipVersion"`
+	DestinationNetworkAddress string `json:"destinationNetworkAddress" yaml:"destinationNetworkAddress"`
+	DestinationPrefixSize     int    `json:"destinationPrefixSize" yaml:"destinationPrefixSize"`
+	NextHopAddress            string `json:"nextHopAddress" yaml:"nextHopAddress"`
+}
+
+type inventoryNATRule struct {
+	ID                string `json:"id" yaml:"id"`
+	InternalIPAddress string `json:"internalIPAddress" yaml:"internalIPAddress"`
+	ExternalIPAddress string `json:"externalIPAddress" yaml:"externalIPAddress"`
+}
+
+type inventoryFirewallRule struct {
+	ID          string                 `json:"id" yaml:"id"`
+	Name        string                 `json:"name" yaml:"name"`
+	RuleType    string                 `json:"ruleType" yaml:"ruleType"`
+	Action      string                 `json:"action" yaml:"action"`
+	IPVersion   string                 `json:"ipVersion" yaml:"ipVersion"`
+	Protocol    string                 `json:"protocol" yaml:"protocol"`
+	Enabled     bool                   `json:"enabled" yaml:"enabled"`
+	Source      inventoryFirewallScope `json:"source" yaml:"source"`
+	Destination inventoryFirewallScope `json:"destination" yaml:"destination"`
+}
+
+type inventoryFirewallScope struct {
+	IPAddress     string `json:"ipAddress,omitempty" yaml:"ipAddress,omitempty"`
+	PrefixSize    *int   `json:"prefixSize,omitempty" yaml:"prefixSize,omitempty"`
+	AddressListID string `json:"addressListId,omitempty" yaml:"addressListId,omitempty"`
+	PortBegin     *int   `json:"portBegin,omitempty" yaml:"portBegin,omitempty"`
+	PortEnd       *int   `json:"portEnd,omitempty" yaml:"portEnd,omitempty"`
+	PortListID    string `json:"portListId,omitempty" yaml:"portListId,omitempty"`
+}
+
+type inventoryPublicIPBlock struct {
+	ID     string `json:"id" yaml:"id"`
+	BaseIP string `json:"baseIP" yaml:"baseIP"`
+	Size   int    `json:"size" yaml:"size"`
+}
+
+type inventoryLoadBalancer struct {
+	VirtualListeners      []inventoryVirtualListener   `json:"virtualListeners" yaml:"virtualListeners"`
+	Pools                 []inventoryVIPPool           `json:"pools" yaml:"pools"`
+	Nodes                 []inventoryVIPNode           `json:"nodes" yaml:"nodes"`
+	SSLOffloadProfiles    []inventorySSLOffloadProfile `json:"sslOffloadProfiles" yaml:"sslOffloadProfiles"`
+	SSLDomainCertificates []inventorySSLCertificate    `json:"sslDomainCertificates" yaml:"sslDomainCertificates"`
+	SSLCertificateChains  []inventorySSLCertificate    `json:"sslCertificateChains" yaml:"sslCertificateChains"`
+}
+
+type inventorySSLOffloadProfile struct {
+	ID                   string `json:"id" yaml:"id"`
+	Name                 string `json:"name" yaml:"name"`
+	Ciphers              string `json:"ciphers,omitempty" yaml:"ciphers,omitempty"`
+	SSLDomainCertificate string `json:"sslDomainCertificate" yaml:"sslDomainCertificate"`
+	SSLCertificateChain  string `json:"sslCertificateChain,omitempty" yaml:"sslCertificateChain,omitempty"`
+}
+
+// An SSL domain certificate or certificate chain (only names and expiry dates are recorded, never key material).
+type inventorySSLCertificate struct {
+	ID         string `json:"id" yaml:"id"`
+	Name       string `json:"name" yaml:"name"`
+	ExpiryTime string `json:"expiryTime" yaml:"expiryTime"`
+}
+
+type inventoryVirtualListener struct {
+	ID                 string `json:"id" yaml:"id"`
+	Name               string `json:"name" yaml:"name"`
+	Type               string `json:"type" yaml:"type"`
+	Protocol           string `json:"protocol" yaml:"protocol"`
+	ListenerIPAddress  string `json:"listenerIPAddress" yaml:"listenerIPAddress"`
+	Port               int    `json:"port" yaml:"port"`
+	Enabled            bool   `json:"enabled" yaml:"enabled"`
+	PoolID             string `json:"poolId,omitempty" yaml:"poolId,omitempty"`
+	PersistenceProfile string `json:"persistenceProfile,omitempty" yaml:"persistenceProfile,omitempty"`
+	SSLOffloadProfile  string `json:"sslOffloadProfile,omitempty" yaml:"sslOffloadProfile,omitempty"`
+}
+
+type inventoryVIPPool struct {
+	ID                string                   `json:"id" yaml:"id"`
+	Name              string                   `json:"name" yaml:"name"`
+	LoadBalanceMethod string                   `json:"loadBalanceMethod" yaml:"loadBalanceMethod"`
+	HealthMonitors    []string                 `json:"healthMonitors" yaml:"healthMonitors"`
+	Members           []inventoryVIPPoolMember `json:"members" yaml:"members"`
+}
+
+type inventoryVIPPoolMember struct {
+	NodeID    string `json:"nodeId" yaml:"nodeId"`
+	IPAddress string `json:"ipAddress" yaml:"ipAddress"`
+	Port      *int   `json:"port,omitempty" yaml:"port,omitempty"`
+}
+
+type inventoryVIPNode struct {
+	ID          string `json:"id" yaml:"id"`
+	Name        string `json:"name" yaml:"name"`
+	IPv4Address string `json:"ipv4Address,omitempty" yaml:"ipv4Address,omitempty"`
+	IPv6Address string `json:"ipv6Address,omitempty" yaml:"ipv6Address,omitempty"`
+}
+
+// Capture an inventory of the target network domain's contents.
+func captureInventory(apiClient *compute.Client, region string, networkDomain *compute.NetworkDomain) (*inventory, error) {
+	log.Printf("Capturing inventory of network domain '%s'...", networkDomain.ID)
+
+	domainInventory := &inventory{
+		CapturedAt: time.Now().UTC().Format(time.RFC3339),
+		Region:     region,
+		NetworkDomain: inventoryNetworkDomain{
+			ID:             networkDomain.ID,
+			Name:           networkDomain.Name,
+			Description:    networkDomain.Description,
+			Type:           networkDomain.Type,
+			DatacenterID:   networkDomain.DatacenterID,
+			NatIPv4Address: networkDomain.NatIPv4Address,
+			CreateTime:     networkDomain.CreateTime,
+		},
+	}
+
+	servers, err := listServers(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range servers {
+		tags, err := listAssetTags(apiClient, server.ID, compute.AssetTypeServer)
+		if err != nil {
+			return nil, err
+		}
+
+		domainInventory.Servers = append(domainInventory.Servers, newInventoryServer(server, tags))
+	}
+
+	antiAffinityRules, err := listServerAntiAffinityRules(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, antiAffinityRule := range antiAffinityRules {
+		rule := inventoryAntiAffinityRule{
+			ID: antiAffinityRule.ID,
+		}
+		for _, server := range antiAffinityRule.Servers {
+			rule.Servers = append(rule.Servers, inventoryServerReference{
+				ID:   server.ID,
+				Name: server.Name,
+			})
+		}
+
+		domainInventory.AntiAffinityRules = append(domainInventory.AntiAffinityRules, rule)
+	}
+
+	vlans, err := listVLANs(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, vlan := range vlans {
+		reservedIPv4Addresses, err := listReservedPrivateIPv4Addresses(apiClient, vlan.ID)
+		if err != nil {
+			return nil, err
+		}
+		reservedIPv6Addresses, err := listReservedIPv6Addresses(apiClient, vlan.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		inventoryVLAN := inventoryVLAN{
+			ID:                 vlan.ID,
+			Name:               vlan.Name,
+			Description:        vlan.Description,
+			IPv4BaseAddress:    vlan.IPv4Range.BaseAddress,
+			IPv4PrefixSize:     vlan.IPv4Range.PrefixSize,
+			IPv4GatewayAddress: vlan.IPv4GatewayAddress,
+			IPv6BaseAddress:    vlan.IPv6Range.BaseAddress,
+			IPv6PrefixSize:     vlan.IPv6Range.PrefixSize,
+			IPv6GatewayAddress: vlan.IPv6GatewayAddress,
+		}
+		for _, reservedAddress := range reservedIPv4Addresses {
+			inventoryVLAN.ReservedIPv4Addresses = append(inventoryVLAN.ReservedIPv4Addresses, reservedAddress.IPAddress)
+		}
+		for _, reservedAddress := range reservedIPv6Addresses {
+			inventoryVLAN.ReservedIPv6Addresses = append(inventoryVLAN.ReservedIPv6Addresses, reservedAddress.IPAddress)
+		}
+
+		domainInventory.VLANs = append(domainInventory.VLANs, inventoryVLAN)
+	}
+
+	staticRoutes, err := listUserStaticRoutes(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, staticRoute := range staticRoutes {
+		domainInventory.StaticRoutes = append(domainInventory.StaticRoutes, inventoryStaticRoute{
+			ID:                        staticRoute.ID,
+			Name:                      staticRoute.Name,
+			IPVersion:                 staticRoute.IPVersion,
+			DestinationNetworkAddress: staticRoute.DestinationNetworkAddress,
+			DestinationPrefixSize:     staticRoute.DestinationPrefixSize,
+			NextHopAddress:            staticRoute.NextHopAddress,
+		})
+	}
+
+	natRules, err := listNATRules(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, natRule := range natRules {
+		domainInventory.NATRules = append(domainInventory.NATRules, inventoryNATRule{
+			ID:                natRule.ID,
+			InternalIPAddress: natRule.InternalIPAddress,
+			ExternalIPAddress: natRule.ExternalIPAddress,
+		})
+	}
+
+	firewallRules, err := listFirewallRules(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, firewallRule := range firewallRules {
+		domainInventory.FirewallRules = append(domainInventory.FirewallRules, inventoryFirewallRule{
+			ID:          firewallRule.ID,
+			Name:        firewallRule.Name,
+			RuleType:    firewallRule.RuleType,
+			Action:      firewallRule.Action,
+			IPVersion:   firewallRule.IPVersion,
+			Protocol:    firewallRule.Protocol,
+			Enabled:     firewallRule.Enabled,
+			Source:      newInventoryFirewallScope(firewallRule.Source),
+			Destination: newInventoryFirewallScope(firewallRule.Destination),
+		})
+	}
+
+	publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, publicIPBlock := range publicIPBlocks {
+		domainInventory.PublicIPBlocks = append(domainInventory.PublicIPBlocks, inventoryPublicIPBlock{
+			ID:     publicIPBlock.ID,
+			BaseIP: publicIPBlock.BaseIP,
+			Size:   publicIPBlock.Size,
+		})
+	}
+
+	err = captureLoadBalancerInventory(apiClient, networkDomain.ID, &domainInventory.LoadBalancer)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Captured inventory of network domain '%s'.", networkDomain.ID)
+
+	return domainInventory, nil
+}
+
+func captureLoadBalancerInventory(apiClient *compute.Client, networkDomainID string, loadBalancer *inventoryLoadBalancer) error {
+	virtualListeners, err := listVirtualListeners(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+	for _, virtualListener := range virtualListeners {
+		loadBalancer.VirtualListeners = append(loadBalancer.VirtualListeners, inventoryVirtualListener{
+			ID:                 virtualListener.ID,
+			Name:               virtualListener.Name,
+			Type:               virtualListener.Type,
+			Protocol:           virtualListener.Protocol,
+			ListenerIPAddress:  virtualListener.ListenerIPAddress,
+			Port:               virtualListener.Port,
+			Enabled:            virtualListener.Enabled,
+			PoolID:             virtualListener.Pool.ID,
+			PersistenceProfile: virtualListener.PersistenceProfile.Name,
+			SSLOffloadProfile:  virtualListener.SSLOffloadProfile.Name,
+		})
+	}
+
+	vipPools, err := listVIPPools(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+	for _, vipPool := range vipPools {
+		pool := inventoryVIPPool{
+			ID:                vipPool.ID,
+			Name:              vipPool.Name,
+			LoadBalanceMethod: vipPool.LoadBalanceMethod,
+		}
+		for _, healthMonitor := range vipPool.HealthMonitors {
+			pool.HealthMonitors = append(pool.HealthMonitors, healthMonitor.Name)
+		}
+
+		members, err := listVIPPoolMembers(apiClient, vipPool.ID)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			pool.Members = append(pool.Members, inventoryVIPPoolMember{
+				NodeID:    member.Node.ID,
+				IPAddress: member.Node.IPAddress,
+				Port:      member.Port,
+			})
+		}
+
+		loadBalancer.Pools = append(loadBalancer.Pools, pool)
+	}
+
+	vipNodes, err := listVIPNodes(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+	for _, vipNode := range vipNodes {
+		loadBalancer.Nodes = append(loadBalancer.Nodes, inventoryVIPNode{
+			ID:          vipNode.ID,
+			Name:        vipNode.Name,
+			IPv4Address: vipNode.IPv4Address,
+			IPv6Address: vipNode.IPv6Address,
+		})
+	}
+
+	sslOffloadProfiles, err := listSSLOffloadProfiles(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+	for _, sslOffloadProfile := range sslOffloadProfiles {
+		loadBalancer.SSLOffloadProfiles = append(loadBalancer.SSLOffloadProfiles, inventorySSLOffloadProfile{
+			ID:                   sslOffloadProfile.ID,
+			Name:                 sslOffloadProfile.Name,
+			Ciphers:              sslOffloadProfile.Ciphers,
+			SSLDomainCertificate: sslOffloadProfile.SSLDomainCertificate.Name,
+			SSLCertificateChain:  sslOffloadProfile.SSLCertificateChain.Name,
+		})
+	}
+
+	sslDomainCertificates, err := listSSLDomainCertificates(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+	for _, sslDomainCertificate := range sslDomainCertificates {
+		loadBalancer.SSLDomainCertificates = append(loadBalancer.SSLDomainCertificates, inventorySSLCertificate{
+			ID:         sslDomainCertificate.ID,
+			Name:       sslDomainCertificate.Name,
+			ExpiryTime: sslDomainCertificate.ExpiryTime,
+		})
+	}
+
+	sslCertificateChains, err := listSSLCertificateChains(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+	for _, sslCertificateChain := range sslCertificateChains {
+		loadBalancer.SSLCertificateChains = append(loadBalancer.SSLCertificateChains, inventorySSLCertificate{
+			ID:         sslCertificateChain.ID,
+			Name:       sslCertificateChain.Name,
+			ExpiryTime: sslCertificateChain.ExpiryTime,
+		})
+	}
+
+	return nil
+}
+
+func newInventoryServer(server compute.Server, tags []compute.TagDetail) inventoryServer {
+	inventoryServer := inventoryServer{
+		ID:                server.ID,
+		Name:              server.Name,
+		Description:       server.Description,
+		SourceImageID:     server.SourceImageID,
+		OperatingSystem:   server.OperatingSystem.DisplayName,
+		CPUCount:          server.CPU.Count,
+		CPUSpeed:          server.CPU.Speed,
+		CPUCoresPerSocket: server.CPU.CoresPerSocket,
+		MemoryGB:          server.MemoryGB,
+		Started:           server.Started,
+		CreateTime:        server.CreateTime,
+	}
+
+	for _, disk := range server.Disks {
+		inventoryServer.Disks = append(inventoryServer.Disks, inventoryServerDisk{
+			ID:         stringOrEmpty(disk.ID),
+			SCSIUnitID: disk.SCSIUnitID,
+			SizeGB:     disk.SizeGB,
+			Speed:      disk.Speed,
+		})
+	}
+
+	inventoryServer.NetworkAdapters = append(inventoryServer.NetworkAdapters,
+		newInventoryNetworkAdapter(server.Network.PrimaryAdapter, true),
+	)
+	for _, networkAdapter := range server.Network.AdditionalNetworkAdapters {
+		inventoryServer.NetworkAdapters = append(inventoryServer.NetworkAdapters,
+			newInventoryNetworkAdapter(networkAdapter, false),
+		)
+	}
+
+	for _, tag := range tags {
+		inventoryServer.Tags = append(inventoryServer.Tags, inventoryTag{
+			Name:  tag.Name,
+			Value: tag.Value,
+		})
+	}
+
+	return inventoryServer
+}
+
+func newInventoryNetworkAdapter(networkAdapter compute.VirtualMachineNetworkAdapter, primary bool) inventoryNetworkAdapter {
+	return inventoryNetworkAdapter{
+		ID:                 stringOrEmpty(networkAdapter.ID),
+		Primary:            primary,
+		VLANID:             stringOrEmpty(networkAdapter.VLANID),
+		VLANName:           stringOrEmpty(networkAdapter.VLANName),
+		PrivateIPv4Address: stringOrEmpty(networkAdapter.PrivateIPv4Address),
+		PrivateIPv6Address: stringOrEmpty(networkAdapter.PrivateIPv6Address),
+		AdapterType:        stringOrEmpty(networkAdapter.AdapterType),
+	}
+}
+
+func newInventoryFirewallScope(scope compute.FirewallRuleScope) inventoryFirewallScope {
+	inventoryScope := inventoryFirewallScope{
+		AddressListID: stringOrEmpty(scope.AddressListID),
+		PortListID:    stringOrEmpty(scope.PortListID),
+	}
+	if scope.IPAddress != nil {
+		inventoryScope.IPAddress = scope.IPAddress.Address
+		inventoryScope.PrefixSize = scope.IPAddress.PrefixSize
+	}
+	if scope.Port != nil {
+		portBegin := scope.Port.Begin
+		inventoryScope.PortBegin = &portBegin
+		inventoryScope.PortEnd = scope.Port.End
+	}
+
+	return inventoryScope
+}
+
+// Write the inventory to a timestamped file in the specified directory.
+//
+// Returns the path of the file that was written.
+func writeInventory(domainInventory *inventory, directory string, format string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(domainInventory, "", "  ")
+	case "yaml":
+		data, err = yaml.Marshal(domainInventory)
+	default:
+		err = fmt.Errorf("Unsupported inventory format '%s' (must be json or yaml).", format)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	capturedAt, err := time.Parse(time.RFC3339, domainInventory.CapturedAt)
+	if err != nil {
+		return "", err
+	}
+	fileName := fmt.Sprintf("nifo-inventory-%s-%s.%s",
+		unsafeFileNameCharacters.ReplaceAllString(domainInventory.NetworkDomain.Name, "_"),
+		capturedAt.Format("20060102T150405Z"),
+		format,
+	)
+	inventoryFile := filepath.Join(directory, fileName)
+
+	log.Printf("Writing inventory to '%s'...", inventoryFile)
+
+	err = ioutil.WriteFile(inventoryFile, data, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return inventoryFile, nil
+}
+
+var unsafeFileNameCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func stringOrEmpty(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
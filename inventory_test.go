@@ -0,0 +1,128 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestInventoryRoundTrip(t *testing.T) {
+	prefixSize := 24
+	port := 443
+	original := &inventory{
+		CapturedAt: "2016-12-01T10:30:00Z",
+		Region:     "AU",
+		NetworkDomain: inventoryNetworkDomain{
+			ID:           "domain-id",
+			Name:         "My network domain",
+			Description:  "Quotes (\"), colons: hashes # and a\nnewline",
+			Type:         "ESSENTIALS",
+			DatacenterID: "AU9",
+		},
+		Servers: []inventoryServer{
+			{
+				ID:              "server1",
+				Name:            "- web-1",
+				SourceImageID:   "image1",
+				OperatingSystem: "UBUNTU14/64",
+				CPUCount:        2,
+				CPUSpeed:        "STANDARD",
+				MemoryGB:        8,
+				Started:         true,
+				Disks: []inventoryServerDisk{
+					{ID: "disk1", SCSIUnitID: 0, SizeGB: 10, Speed: "STANDARD"},
+					{ID: "disk2", SCSIUnitID: 1, SizeGB: 100, Speed: "ECONOMY"},
+				},
+				NetworkAdapters: []inventoryNetworkAdapter{
+					{ID: "nic1", Primary: true, VLANID: "vlan1", VLANName: "VLAN 1", PrivateIPv4Address: "10.0.0.10"},
+				},
+				Tags: []inventoryTag{
+					{Name: "owner", Value: "ops@example.com"},
+					{Name: "empty", Value: ""},
+				},
+			},
+		},
+		VLANs: []inventoryVLAN{
+			{
+				ID:                    "vlan1",
+				Name:                  "VLAN 1",
+				IPv4BaseAddress:       "10.0.0.0",
+				IPv4PrefixSize:        24,
+				ReservedIPv4Addresses: []string{"10.0.0.5", "10.0.0.6"},
+				ReservedIPv6Addresses: []string{},
+			},
+		},
+		NATRules: []inventoryNATRule{
+			{ID: "nat1", InternalIPAddress: "10.0.0.10", ExternalIPAddress: "168.128.1.10"},
+		},
+		FirewallRules: []inventoryFirewallRule{
+			{
+				ID:          "rule1",
+				Name:        "Allow_HTTPS",
+				Action:      "ACCEPT_DECISIVELY",
+				IPVersion:   "IPV4",
+				Protocol:    "TCP",
+				Enabled:     true,
+				Source:      inventoryFirewallScope{IPAddress: "0.0.0.0", PrefixSize: &prefixSize},
+				Destination: inventoryFirewallScope{IPAddress: "168.128.1.10", PortBegin: &port},
+			},
+		},
+		PublicIPBlocks: []inventoryPublicIPBlock{
+			{ID: "block1", BaseIP: "168.128.1.10", Size: 2},
+		},
+		LoadBalancer: inventoryLoadBalancer{
+			VirtualListeners:      []inventoryVirtualListener{},
+			Pools:                 []inventoryVIPPool{{ID: "pool1", Name: "pool", HealthMonitors: []string{"CCDEFAULT.Http"}, Members: []inventoryVIPPoolMember{{NodeID: "node1", Port: &port}}}},
+			Nodes:                 []inventoryVIPNode{{ID: "node1", Name: "node 1", IPv4Address: "10.0.0.10"}},
+			SSLOffloadProfiles:    []inventorySSLOffloadProfile{},
+			SSLDomainCertificates: []inventorySSLCertificate{},
+			SSLCertificateChains:  []inventorySSLCertificate{},
+		},
+		AntiAffinityRules: []inventoryAntiAffinityRule{
+			{ID: "rule1", Servers: []inventoryServerReference{{ID: "server1", Name: "web-1"}, {ID: "server2", Name: "web-2"}}},
+		},
+		StaticRoutes: []inventoryStaticRoute{},
+	}
+
+	directory, err := ioutil.TempDir("", "nifo-inventory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+
+	for _, format := range []string{"json", "yaml"} {
+		inventoryFile, err := writeInventory(original, directory, format)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		roundTripped, err := readInventory(inventoryFile)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+
+		if !reflect.DeepEqual(original, roundTripped) {
+			expected, _ := json.MarshalIndent(original, "", "  ")
+			actual, _ := json.MarshalIndent(roundTripped, "", "  ")
+			t.Errorf("%s inventory did not round-trip.\nExpected:\n%s\nActual:\n%s", format, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,574 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// janitorOptions represents the options for the "janitor" command.
+type janitorOptions struct {
+	Targets  []string      `long:"target" description:"A region (e.g. AU) or region/datacenter (e.g. AU/AU9) to clean up (can be specified multiple times; defaults to --region / --datacenter)."`
+	Interval time.Duration `long:"interval" default:"15m" description:"The time to wait between cleanup cycles."`
+	LogFile  string        `long:"log-file" default:"nifo-janitor.log" description:"The file where a structured (JSON) record of each cleanup cycle is appended."`
+	Once     bool          `long:"once" description:"Run a single cleanup cycle and then exit."`
+
+	WarnBefore []time.Duration `long:"warn-before" default:"24h" default:"1h" description:"Warn this long before a network domain expires (can be specified multiple times)."`
+	WarnURL    string          `long:"warn-url" description:"A webhook URL to which expiry warnings are posted (as JSON)."`
+	OwnerTag   string          `long:"owner-tag" default:"owner" description:"The name of the tag that identifies a network domain's owner (included in expiry warnings)."`
+
+	MetricsListen string `long:"metrics-listen" description:"The address (e.g. :9090) on which to serve Prometheus metrics (/metrics) and health (/healthz) endpoints."`
+}
+
+// The largest janitor cycle record that can be read back from the janitor log.
+const maxJanitorCycleLogSize = 16 * 1024 * 1024
+
+// The tags used to give a network domain a limited lifetime.
+const (
+	// An absolute expiry time (e.g. nifo:expires=2016-11-01T00:00Z).
+	expiresTagName = "nifo:expires"
+
+	// A time-to-live, relative to the network domain's creation time (e.g. nifo:ttl=72h or nifo:ttl=7d).
+	ttlTagName = "nifo:ttl"
+)
+
+// A janitorTarget is a region (and, optionally, a datacenter in that region) whose network domains are cleaned up by the janitor.
+type janitorTarget struct {
+	Region     string
+	Datacenter string
+}
+
+// String returns the target in region/datacenter form.
+func (target janitorTarget) String() string {
+	if target.Datacenter == "" {
+		return target.Region
+	}
+
+	return target.Region + "/" + target.Datacenter
+}
+
+// A janitorCycleLog is the structured record of a single janitor cycle.
+type janitorCycleLog struct {
+	startedAt time.Time
+
+	Cycle                 int                   `json:"cycle"`
+	StartedAt             string                `json:"startedAt"`
+	Duration              string                `json:"duration"`
+	Targets               []string              `json:"targets"`
+	NetworkDomainsChecked int                   `json:"networkDomainsChecked"`
+	Warnings              []expiryWarning       `json:"warnings"`
+	Expiring              []string              `json:"expiring,omitempty"`
+	Expired               []janitorDomainResult `json:"expired"`
+	Errors                []string              `json:"errors"`
+}
+
+// A janitorCheck records when the janitor last checked for expired network domains, and which of them were then
+// inside an expiry warning threshold.
+type janitorCheck struct {
+	At       time.Time
+	Expiring map[string]bool
+}
+
+// Create a janitorCheck from the record of a janitor cycle.
+func newJanitorCheck(cycleLog *janitorCycleLog) janitorCheck {
+	check := janitorCheck{
+		At:       cycleLog.startedAt,
+		Expiring: make(map[string]bool),
+	}
+	for _, networkDomainID := range cycleLog.Expiring {
+		check.Expiring[networkDomainID] = true
+	}
+
+	return check
+}
+
+// A janitorDomainResult records what the janitor did with an expired network domain.
+type janitorDomainResult struct {
+	Region            string `json:"region"`
+	DatacenterID      string `json:"datacenterId"`
+	NetworkDomainID   string `json:"networkDomainId"`
+	NetworkDomainName string `json:"networkDomainName"`
+	ExpiredAt         string `json:"expiredAt"`
+	Outcome           string `json:"outcome"`
+	Error             string `json:"error,omitempty"`
+}
+
+// An expiryWarning notifies a network domain's owner that it will soon be nuked by the janitor.
+type expiryWarning struct {
+	Region            string `json:"region"`
+	DatacenterID      string `json:"datacenterId"`
+	NetworkDomainID   string `json:"networkDomainId"`
+	NetworkDomainName string `json:"networkDomainName"`
+	Owner             string `json:"owner,omitempty"`
+	ExpiresAt         string `json:"expiresAt"`
+	Remaining         string `json:"remaining"`
+}
+
+// Successful determines whether the cycle completed without errors (and without failing to nuke any expired network domains).
+func (cycleLog *janitorCycleLog) Successful() bool {
+	return len(cycleLog.Errors) == 0 && cycleLog.FailedCount() == 0
+}
+
+// FailedCount determines the number of expired network domains that the cycle failed to nuke.
+func (cycleLog *janitorCycleLog) FailedCount() int {
+	failed := 0
+	for _, result := range cycleLog.Expired {
+		if result.Outcome == "failed" {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// Parse the janitor's targets (defaulting to the region and datacenter specified by --region and --datacenter).
+func (options programOptions) JanitorTargets() ([]janitorTarget, error) {
+	if len(options.Janitor.Targets) == 0 {
+		return []janitorTarget{
+			{Region: options.Region, Datacenter: options.Datacenter},
+		}, nil
+	}
+
+	var targets []janitorTarget
+	for _, target := range options.Janitor.Targets {
+		targetParts := strings.Split(target, "/")
+		if len(targetParts) > 2 || targetParts[0] == "" {
+			return nil, fmt.Errorf("Invalid janitor target '%s' (must be region or region/datacenter).", target)
+		}
+
+		janitorTarget := janitorTarget{Region: targetParts[0]}
+		if len(targetParts) == 2 {
+			janitorTarget.Datacenter = targetParts[1]
+		}
+
+		targets = append(targets, janitorTarget)
+	}
+
+	return targets, nil
+}
+
+// Periodically nuke network domains whose expiry time (or time-to-live) has passed.
+func janitor(options programOptions) error {
+	targets, err := options.JanitorTargets()
+	if err != nil {
+		return err
+	}
+
+	// Unattended, so don't ask for confirmation (external reference checks still apply).
+	options.Force = true
+
+	if options.Janitor.MetricsListen != "" {
+		err = serveJanitorMetrics(options.Janitor.MetricsListen, options.Janitor.Interval)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Warnings are issued the first time a network domain is seen inside a threshold, and for thresholds crossed since
+	// the previous check (so they are only issued once per threshold).
+	//
+	// The previous check may have been made by another process (e.g. when running from cron with --once), so it is taken
+	// from the janitor log if possible.
+	lastCheck := janitorCheck{
+		At: time.Now().Add(-options.Janitor.Interval),
+	}
+	firstCycle := 1
+	previousCycleLog, err := readLastJanitorCycleLog(options.Janitor.LogFile)
+	if err != nil {
+		logger.Printf("Unable to determine when the janitor last ran (%s); assuming %s ago.", err, options.Janitor.Interval)
+	} else if previousCycleLog != nil {
+		lastCheck = newJanitorCheck(previousCycleLog)
+		firstCycle = previousCycleLog.Cycle + 1
+	}
+
+	for cycle := firstCycle; ; cycle++ {
+		cycleLog := runJanitorCycle(cycle, targets, lastCheck, options)
+		lastCheck = newJanitorCheck(cycleLog)
+		metrics.CycleCompleted(cycleLog.Successful())
+
+		err = writeJanitorCycleLog(cycleLog, options.Janitor.LogFile)
+		if err != nil {
+			logger.Printf("Failed to write janitor log: %s", err)
+		}
+
+		if options.Janitor.Once {
+			if !cycleLog.Successful() {
+				return fmt.Errorf("Janitor cycle completed with %d error(s) and %d network domain(s) that could not be nuked.",
+					len(cycleLog.Errors),
+					cycleLog.FailedCount(),
+				)
+			}
+
+			return nil
+		}
+
+		logger.Printf("Next janitor cycle in %s.", options.Janitor.Interval)
+		time.Sleep(options.Janitor.Interval)
+	}
+}
+
+// Run a single janitor cycle.
+func runJanitorCycle(cycle int, targets []janitorTarget, lastCheck janitorCheck, options programOptions) *janitorCycleLog {
+	startedAt := time.Now().UTC()
+	cycleLog := &janitorCycleLog{
+		startedAt: startedAt,
+		Cycle:     cycle,
+		StartedAt: startedAt.Format(time.RFC3339Nano),
+	}
+
+	logger.Printf("Starting janitor cycle %d...", cycle)
+
+	for _, target := range targets {
+		cycleLog.Targets = append(cycleLog.Targets, target.String())
+
+		err := cleanUpJanitorTarget(target, lastCheck, options, cycleLog)
+		if err != nil {
+			logger.Printf("Janitor failed to check '%s': %s", target, err)
+			cycleLog.Errors = append(cycleLog.Errors, fmt.Sprintf("%s: %s", target, err))
+		}
+	}
+
+	cycleLog.Duration = time.Since(startedAt).String()
+
+	logger.Printf("Completed janitor cycle %d (checked %d network domain(s), %d expiring soon, %d expired).",
+		cycle,
+		cycleLog.NetworkDomainsChecked,
+		len(cycleLog.Warnings),
+		len(cycleLog.Expired),
+	)
+
+	return cycleLog
+}
+
+// Nuke the expired network domains in a janitor target (and warn about those that will soon expire).
+func cleanUpJanitorTarget(target janitorTarget, lastCheck janitorCheck, options programOptions, cycleLog *janitorCycleLog) error {
+	targetOptions := options
+	targetOptions.Region = target.Region
+	targetOptions.Datacenter = target.Datacenter
+
+	apiClient, err := targetOptions.CreateClient()
+	if err != nil {
+		return err
+	}
+
+	networkDomains, err := listNetworkDomainsInDatacenterOrRegion(apiClient, target.Datacenter)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, networkDomain := range networkDomains {
+		cycleLog.NetworkDomainsChecked++
+
+		tags, err := listAssetTags(apiClient, networkDomain.ID, compute.AssetTypeNetworkDomain)
+		if err != nil {
+			logger.Printf("Failed to retrieve tags for network domain '%s' ('%s'): %s", networkDomain.Name, networkDomain.ID, err)
+			cycleLog.Errors = append(cycleLog.Errors, fmt.Sprintf("network domain '%s': %s", networkDomain.ID, err))
+
+			// Don't warn again just because the network domain could not be checked this time.
+			if lastCheck.Expiring[networkDomain.ID] {
+				cycleLog.Expiring = append(cycleLog.Expiring, networkDomain.ID)
+			}
+
+			continue
+		}
+
+		expiry, hasExpiry, err := networkDomainExpiry(networkDomain, tags)
+		if err != nil {
+			cycleLog.Errors = append(cycleLog.Errors, fmt.Sprintf("network domain '%s': %s", networkDomain.ID, err))
+
+			continue
+		}
+		if !hasExpiry {
+			continue
+		}
+		if expiry.After(now) {
+			if !isExpiringSoon(expiry, now, options.Janitor.WarnBefore) {
+				continue
+			}
+			cycleLog.Expiring = append(cycleLog.Expiring, networkDomain.ID)
+
+			if !shouldWarnOfExpiry(expiry, lastCheck.Expiring[networkDomain.ID], lastCheck.At, now, options.Janitor.WarnBefore) {
+				continue
+			}
+
+			warning := expiryWarning{
+				Region:            target.Region,
+				DatacenterID:      networkDomain.DatacenterID,
+				NetworkDomainID:   networkDomain.ID,
+				NetworkDomainName: networkDomain.Name,
+				Owner:             findTagValue(tags, options.Janitor.OwnerTag),
+				ExpiresAt:         expiry.UTC().Format(time.RFC3339),
+				Remaining:         (expiry.Sub(now) / time.Minute * time.Minute).String(),
+			}
+			cycleLog.Warnings = append(cycleLog.Warnings, warning)
+
+			err = warnOfExpiry(warning, options.Janitor.WarnURL)
+			if err != nil {
+				cycleLog.Errors = append(cycleLog.Errors, fmt.Sprintf("network domain '%s': %s", networkDomain.ID, err))
+			}
+
+			continue
+		}
+
+		result := janitorDomainResult{
+			Region:            target.Region,
+			DatacenterID:      networkDomain.DatacenterID,
+			NetworkDomainID:   networkDomain.ID,
+			NetworkDomainName: networkDomain.Name,
+			ExpiredAt:         expiry.UTC().Format(time.RFC3339),
+			Outcome:           "nuked",
+		}
+
+		logger.Printf("Network domain '%s' ('%s') expired at %s; nuking it...",
+			networkDomain.Name,
+			networkDomain.ID,
+			result.ExpiredAt,
+		)
+
+		domainOptions := targetOptions
+		domainOptions.NetworkDomain = networkDomain.Name
+		domainOptions.Datacenter = networkDomain.DatacenterID
+
+		err = runNuke(apiClient, &networkDomain, domainOptions)
+		if err == nil {
+			metrics.AddDomainDeleted()
+		} else if err == errExternalReferences {
+			result.Outcome = "skipped"
+			result.Error = err.Error()
+		} else if err != nil {
+			result.Outcome = "failed"
+			result.Error = err.Error()
+		}
+
+		cycleLog.Expired = append(cycleLog.Expired, result)
+	}
+
+	return nil
+}
+
+// Determine when a network domain expires, based on its expiry or time-to-live tags (if it has both, the earliest applies).
+func networkDomainExpiry(networkDomain compute.NetworkDomain, tags []compute.TagDetail) (expiry time.Time, hasExpiry bool, err error) {
+	for _, tag := range tags {
+		var tagExpiry time.Time
+		switch tag.Name {
+		case expiresTagName:
+			tagExpiry, err = parseExpiryTime(tag.Value)
+			if err != nil {
+				return
+			}
+		case ttlTagName:
+			var (
+				ttl        time.Duration
+				createTime time.Time
+			)
+			ttl, err = parseTTL(tag.Value)
+			if err != nil {
+				return
+			}
+			createTime, err = time.Parse(time.RFC3339, networkDomain.CreateTime)
+			if err != nil {
+				err = fmt.Errorf("Cannot determine creation time of network domain '%s'.", networkDomain.ID)
+
+				return
+			}
+
+			tagExpiry = createTime.Add(ttl)
+		default:
+			continue
+		}
+
+		if !hasExpiry || tagExpiry.Before(expiry) {
+			expiry = tagExpiry
+			hasExpiry = true
+		}
+	}
+
+	return
+}
+
+// Determine whether a network domain that expires at the specified time is now inside any of the warning thresholds.
+func isExpiringSoon(expiry time.Time, now time.Time, warnBefore []time.Duration) bool {
+	for _, threshold := range warnBefore {
+		if !expiry.Add(-threshold).After(now) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Determine whether to warn of a network domain's expiry; this is the case if the network domain was not inside a
+// warning threshold at the previous check (e.g. because it is new, or its time-to-live is shorter than every
+// threshold), or if a warning threshold has been crossed between the previous check and now.
+func shouldWarnOfExpiry(expiry time.Time, wasExpiringSoon bool, lastChecked time.Time, now time.Time, warnBefore []time.Duration) bool {
+	for _, threshold := range warnBefore {
+		warnAt := expiry.Add(-threshold)
+		if warnAt.After(now) {
+			continue
+		}
+		if !wasExpiringSoon || warnAt.After(lastChecked) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Log an expiry warning and (if configured) post it to the warning webhook.
+func warnOfExpiry(warning expiryWarning, warnURL string) error {
+	owner := warning.Owner
+	if owner == "" {
+		owner = "unknown"
+	}
+	logger.Printf("WARNING: network domain '%s' ('%s', owner '%s') expires at %s (in %s) and will then be nuked.",
+		warning.NetworkDomainName,
+		warning.NetworkDomainID,
+		owner,
+		warning.ExpiresAt,
+		warning.Remaining,
+	)
+
+	if warnURL == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(warning)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Post(warnURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("Expiry warning webhook returned unexpected status '%s'.", response.Status)
+	}
+
+	return nil
+}
+
+// Find the value of the tag with the specified name (or an empty string if there is no such tag).
+func findTagValue(tags []compute.TagDetail, name string) string {
+	for _, tag := range tags {
+		if tag.Name == name {
+			return tag.Value
+		}
+	}
+
+	return ""
+}
+
+// The supported formats for expiry times.
+var expiryTimeFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Parse an expiry time (times without a time zone are treated as UTC).
+func parseExpiryTime(value string) (time.Time, error) {
+	for _, format := range expiryTimeFormats {
+		expiry, err := time.Parse(format, value)
+		if err == nil {
+			return expiry, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Invalid expiry time '%s' (expected a time such as 2016-11-01T00:00Z).", value)
+}
+
+// Parse a time-to-live (a Go duration such as 72h, or a number of days such as 7d).
+func parseTTL(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err == nil && days >= 0 {
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 {
+		return 0, fmt.Errorf("Invalid time-to-live '%s' (expected a duration such as 72h or 7d).", value)
+	}
+
+	return ttl, nil
+}
+
+// Append a janitor cycle's structured log to the log file.
+func writeJanitorCycleLog(cycleLog *janitorCycleLog, logFile string) error {
+	data, err := json.Marshal(cycleLog)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+
+	return err
+}
+
+// Read the record of the most recent janitor cycle from the janitor log (returns nil if there is none).
+func readLastJanitorCycleLog(logFile string) (*janitorCycleLog, error) {
+	file, err := os.Open(logFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lastLine, err := readLastLine(file, maxJanitorCycleLogSize)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read janitor log '%s' (%s).", logFile, err)
+	}
+	if lastLine == "" {
+		return nil, nil
+	}
+
+	cycleLog := &janitorCycleLog{}
+	err = json.Unmarshal([]byte(lastLine), cycleLog)
+	if err != nil {
+		return nil, fmt.Errorf("Janitor log '%s' is corrupt (the last record is not valid JSON).", logFile)
+	}
+	cycleLog.startedAt, err = time.Parse(time.RFC3339Nano, cycleLog.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("Janitor log '%s' is corrupt (the last record has an invalid start time '%s').", logFile, cycleLog.StartedAt)
+	}
+
+	return cycleLog, nil
+}
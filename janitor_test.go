@@ -0,0 +1,236 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJanitorResumesFromLastCycle(t *testing.T) {
+	directory, err := ioutil.TempDir("", "nifo-janitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	logFile := filepath.Join(directory, "janitor.log")
+
+	previousCycleLog, err := readLastJanitorCycleLog(logFile)
+	if err != nil || previousCycleLog != nil {
+		t.Fatalf("Expected no previous cycle for a new janitor log, but got %+v (%v)", previousCycleLog, err)
+	}
+
+	startedAt := time.Date(2016, 11, 1, 0, 0, 0, 500, time.UTC)
+	for cycle := 1; cycle <= 2; cycle++ {
+		err = writeJanitorCycleLog(&janitorCycleLog{
+			Cycle:     cycle,
+			StartedAt: startedAt.Add(time.Duration(cycle) * time.Minute).Format(time.RFC3339Nano),
+		}, logFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	previousCycleLog, err = readLastJanitorCycleLog(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previousCycleLog.Cycle != 2 || !previousCycleLog.startedAt.Equal(startedAt.Add(2*time.Minute)) {
+		t.Errorf("Expected cycle 2 started at %s, but got cycle %d started at %s", startedAt.Add(2*time.Minute), previousCycleLog.Cycle, previousCycleLog.startedAt)
+	}
+}
+
+func TestShouldWarnOfExpiry(t *testing.T) {
+	now := time.Date(2016, 11, 1, 12, 0, 0, 0, time.UTC)
+	lastChecked := now.Add(-15 * time.Minute)
+	warnBefore := []time.Duration{24 * time.Hour, time.Hour}
+
+	testCases := []struct {
+		Name            string
+		Expiry          time.Time
+		WasExpiringSoon bool
+		ExpiringSoon    bool
+		Warn            bool
+	}{
+		{
+			Name:   "outside every threshold",
+			Expiry: now.Add(48 * time.Hour),
+		},
+		{
+			Name:            "crossed first threshold",
+			Expiry:          now.Add(24*time.Hour - 5*time.Minute),
+			WasExpiringSoon: false,
+			ExpiringSoon:    true,
+			Warn:            true,
+		},
+		{
+			Name:            "already warned for first threshold",
+			Expiry:          now.Add(12 * time.Hour),
+			WasExpiringSoon: true,
+			ExpiringSoon:    true,
+		},
+		{
+			Name:            "crossed second threshold",
+			Expiry:          now.Add(55 * time.Minute),
+			WasExpiringSoon: true,
+			ExpiringSoon:    true,
+			Warn:            true,
+		},
+		{
+			Name:            "already warned for second threshold",
+			Expiry:          now.Add(30 * time.Minute),
+			WasExpiringSoon: true,
+			ExpiringSoon:    true,
+		},
+		{
+			Name:         "first seen with time-to-live shorter than every threshold",
+			Expiry:       now.Add(30 * time.Minute),
+			ExpiringSoon: true,
+			Warn:         true,
+		},
+		{
+			Name:         "first seen inside first threshold",
+			Expiry:       now.Add(12 * time.Hour),
+			ExpiringSoon: true,
+			Warn:         true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if actual := isExpiringSoon(testCase.Expiry, now, warnBefore); actual != testCase.ExpiringSoon {
+			t.Errorf("%s: expected expiring soon to be %t, but got %t", testCase.Name, testCase.ExpiringSoon, actual)
+		}
+		if actual := shouldWarnOfExpiry(testCase.Expiry, testCase.WasExpiringSoon, lastChecked, now, warnBefore); actual != testCase.Warn {
+			t.Errorf("%s: expected warn to be %t, but got %t", testCase.Name, testCase.Warn, actual)
+		}
+	}
+}
+
+func TestJanitorCheckFromCycleLog(t *testing.T) {
+	startedAt := time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)
+	check := newJanitorCheck(&janitorCycleLog{
+		startedAt: startedAt,
+		Expiring:  []string{"domain-1", "domain-2"},
+	})
+	if !check.At.Equal(startedAt) {
+		t.Errorf("Expected check at %s, but got %s", startedAt, check.At)
+	}
+	if !check.Expiring["domain-1"] || !check.Expiring["domain-2"] || check.Expiring["domain-3"] {
+		t.Errorf("Unexpected expiring network domains %v", check.Expiring)
+	}
+}
+
+func TestJanitorCycleSuccessful(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		CycleLog janitorCycleLog
+		Expected bool
+	}{
+		{"nothing expired", janitorCycleLog{}, true},
+		{"nuked", janitorCycleLog{Expired: []janitorDomainResult{{Outcome: "nuked"}}}, true},
+		{"failed to nuke", janitorCycleLog{Expired: []janitorDomainResult{{Outcome: "nuked"}, {Outcome: "failed"}}}, false},
+		{"failed to check target", janitorCycleLog{Errors: []string{"AU: boom"}}, false},
+	}
+
+	for _, testCase := range testCases {
+		if actual := testCase.CycleLog.Successful(); actual != testCase.Expected {
+			t.Errorf("%s: expected %t, but got %t", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	testCases := []struct {
+		Value    string
+		Expected time.Duration
+		Error    bool
+	}{
+		{Value: "7d", Expected: 7 * 24 * time.Hour},
+		{Value: "0d", Expected: 0},
+		{Value: "72h", Expected: 72 * time.Hour},
+		{Value: "90m", Expected: 90 * time.Minute},
+		{Value: "1h30m", Expected: 90 * time.Minute},
+		{Value: "7", Error: true},
+		{Value: "7w", Error: true},
+		{Value: "1d12h", Error: true},
+		{Value: "-1d", Error: true},
+		{Value: "-72h", Error: true},
+		{Value: "d", Error: true},
+		{Value: "", Error: true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := parseTTL(testCase.Value)
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("'%s': expected an error, but got %s", testCase.Value, actual)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %s", testCase.Value, err)
+
+			continue
+		}
+		if actual != testCase.Expected {
+			t.Errorf("'%s': expected %s, but got %s", testCase.Value, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestParseExpiryTime(t *testing.T) {
+	testCases := []struct {
+		Value    string
+		Expected time.Time
+		Error    bool
+	}{
+		{Value: "2016-11-01T10:30:15Z", Expected: time.Date(2016, 11, 1, 10, 30, 15, 0, time.UTC)},
+		{Value: "2016-11-01T10:30:15.5Z", Expected: time.Date(2016, 11, 1, 10, 30, 15, 500000000, time.UTC)},
+		{Value: "2016-11-01T20:30:15+10:00", Expected: time.Date(2016, 11, 1, 10, 30, 15, 0, time.UTC)},
+		{Value: "2016-11-01T10:30Z", Expected: time.Date(2016, 11, 1, 10, 30, 0, 0, time.UTC)},
+		{Value: "2016-11-01T20:30+10:00", Expected: time.Date(2016, 11, 1, 10, 30, 0, 0, time.UTC)},
+		{Value: "2016-11-01T10:30", Expected: time.Date(2016, 11, 1, 10, 30, 0, 0, time.UTC)},
+		{Value: "2016-11-01", Expected: time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{Value: "2016-11-01 10:30", Error: true},
+		{Value: "01/11/2016", Error: true},
+		{Value: "2016-13-01", Error: true},
+		{Value: "tomorrow", Error: true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := parseExpiryTime(testCase.Value)
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("'%s': expected an error, but got %s", testCase.Value, actual)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %s", testCase.Value, err)
+
+			continue
+		}
+		if !actual.Equal(testCase.Expected) {
+			t.Errorf("'%s': expected %s, but got %s", testCase.Value, testCase.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,488 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// The number of items to retrieve per page when listing resources.
+const listPageSize = 20
+
+// List all NAT rules in the target network domain.
+func listNATRules(apiClient *compute.Client, networkDomainID string) ([]compute.NATRule, error) {
+	var natRules []compute.NATRule
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListNATRules(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		natRules = append(natRules, result.Rules...)
+
+		page.Next()
+	}
+
+	return natRules, nil
+}
+
+// List all public IP blocks in the target network domain.
+func listPublicIPBlocks(apiClient *compute.Client, networkDomainID string) ([]compute.PublicIPBlock, error) {
+	var publicIPBlocks []compute.PublicIPBlock
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListPublicIPBlocks(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		publicIPBlocks = append(publicIPBlocks, result.Blocks...)
+
+		page.Next()
+	}
+
+	return publicIPBlocks, nil
+}
+
+// List all servers in the target network domain.
+func listServers(apiClient *compute.Client, networkDomainID string) ([]compute.Server, error) {
+	var servers []compute.Server
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListServersInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		servers = append(servers, result.Items...)
+
+		page.Next()
+	}
+
+	return servers, nil
+}
+
+// List all VLANs in the target network domain.
+func listVLANs(apiClient *compute.Client, networkDomainID string) ([]compute.VLAN, error) {
+	var vlans []compute.VLAN
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListVLANs(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		vlans = append(vlans, result.VLANs...)
+
+		page.Next()
+	}
+
+	return vlans, nil
+}
+
+// List all tags applied to the target asset.
+func listAssetTags(apiClient *compute.Client, assetID string, assetType string) ([]compute.TagDetail, error) {
+	var tags []compute.TagDetail
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.GetAssetTags(assetID, assetType, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		tags = append(tags, result.Items...)
+
+		page.Next()
+	}
+
+	return tags, nil
+}
+
+// List all firewall rules in the target network domain.
+func listFirewallRules(apiClient *compute.Client, networkDomainID string) ([]compute.FirewallRule, error) {
+	var firewallRules []compute.FirewallRule
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListFirewallRules(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		firewallRules = append(firewallRules, result.Rules...)
+
+		page.Next()
+	}
+
+	return firewallRules, nil
+}
+
+// List all virtual listeners in the target network domain.
+func listVirtualListeners(apiClient *compute.Client, networkDomainID string) ([]compute.VirtualListener, error) {
+	var virtualListeners []compute.VirtualListener
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListVirtualListenersInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		virtualListeners = append(virtualListeners, result.Items...)
+
+		page.Next()
+	}
+
+	return virtualListeners, nil
+}
+
+// List all VIP pools in the target network domain.
+func listVIPPools(apiClient *compute.Client, networkDomainID string) ([]compute.VIPPool, error) {
+	var vipPools []compute.VIPPool
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListVIPPoolsInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		vipPools = append(vipPools, result.Items...)
+
+		page.Next()
+	}
+
+	return vipPools, nil
+}
+
+// List all members of the target VIP pool.
+func listVIPPoolMembers(apiClient *compute.Client, vipPoolID string) ([]compute.VIPPoolMember, error) {
+	var vipPoolMembers []compute.VIPPoolMember
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListVIPPoolMembers(vipPoolID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		vipPoolMembers = append(vipPoolMembers, result.Items...)
+
+		page.Next()
+	}
+
+	return vipPoolMembers, nil
+}
+
+// List all VIP nodes in the target network domain.
+func listVIPNodes(apiClient *compute.Client, networkDomainID string) ([]compute.VIPNode, error) {
+	var vipNodes []compute.VIPNode
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListVIPNodesInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		vipNodes = append(vipNodes, result.Items...)
+
+		page.Next()
+	}
+
+	return vipNodes, nil
+}
+
+// List all server anti-affinity rules in the target network domain.
+func listServerAntiAffinityRules(apiClient *compute.Client, networkDomainID string) ([]compute.ServerAntiAffinityRule, error) {
+	var antiAffinityRules []compute.ServerAntiAffinityRule
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListServerAntiAffinityRules(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		antiAffinityRules = append(antiAffinityRules, result.Items...)
+
+		page.Next()
+	}
+
+	return antiAffinityRules, nil
+}
+
+// List all reserved private IPv4 addresses in the target VLAN.
+func listReservedPrivateIPv4Addresses(apiClient *compute.Client, vlanID string) ([]compute.ReservedPrivateIPv4Address, error) {
+	var reservedAddresses []compute.ReservedPrivateIPv4Address
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListReservedPrivateIPv4AddressesInVLAN(vlanID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		reservedAddresses = append(reservedAddresses, result.Items...)
+
+		page.Next()
+	}
+
+	return reservedAddresses, nil
+}
+
+// List all reserved IPv6 addresses in the target VLAN.
+func listReservedIPv6Addresses(apiClient *compute.Client, vlanID string) ([]compute.ReservedIPv6Address, error) {
+	var reservedAddresses []compute.ReservedIPv6Address
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListReservedIPv6AddressesInVLAN(vlanID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		reservedAddresses = append(reservedAddresses, result.Items...)
+
+		page.Next()
+	}
+
+	return reservedAddresses, nil
+}
+
+// List all static routes in the target network domain.
+func listStaticRoutes(apiClient *compute.Client, networkDomainID string) ([]compute.StaticRoute, error) {
+	var staticRoutes []compute.StaticRoute
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListStaticRoutes(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		staticRoutes = append(staticRoutes, result.Items...)
+
+		page.Next()
+	}
+
+	return staticRoutes, nil
+}
+
+// List all network domains in the target datacenter.
+func listNetworkDomains(apiClient *compute.Client, datacenterID string) ([]compute.NetworkDomain, error) {
+	var networkDomains []compute.NetworkDomain
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListNetworkDomainsInDatacenter(datacenterID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		networkDomains = append(networkDomains, result.Domains...)
+
+		page.Next()
+	}
+
+	return networkDomains, nil
+}
+
+// List all IP address lists in the target network domain.
+func listIPAddressLists(apiClient *compute.Client, networkDomainID string) ([]compute.IPAddressList, error) {
+	var ipAddressLists []compute.IPAddressList
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListIPAddressLists(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		ipAddressLists = append(ipAddressLists, result.AddressLists...)
+
+		page.Next()
+	}
+
+	return ipAddressLists, nil
+}
+
+// List all SSL offload profiles in the target network domain.
+func listSSLOffloadProfiles(apiClient *compute.Client, networkDomainID string) ([]compute.SSLOffloadProfile, error) {
+	var sslOffloadProfiles []compute.SSLOffloadProfile
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListSSLOffloadProfilesInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		sslOffloadProfiles = append(sslOffloadProfiles, result.Items...)
+
+		page.Next()
+	}
+
+	return sslOffloadProfiles, nil
+}
+
+// List all SSL domain certificates in the target network domain.
+func listSSLDomainCertificates(apiClient *compute.Client, networkDomainID string) ([]compute.SSLDomainCertificate, error) {
+	var sslDomainCertificates []compute.SSLDomainCertificate
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListSSLDomainCertificatesInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		sslDomainCertificates = append(sslDomainCertificates, result.Items...)
+
+		page.Next()
+	}
+
+	return sslDomainCertificates, nil
+}
+
+// List all SSL certificate chains in the target network domain.
+func listSSLCertificateChains(apiClient *compute.Client, networkDomainID string) ([]compute.SSLCertificateChain, error) {
+	var sslCertificateChains []compute.SSLCertificateChain
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListSSLCertificateChainsInNetworkDomain(networkDomainID, page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		sslCertificateChains = append(sslCertificateChains, result.Items...)
+
+		page.Next()
+	}
+
+	return sslCertificateChains, nil
+}
+
+// List all network domains in the target datacenter (or, if no datacenter is specified, the entire region).
+func listNetworkDomainsInDatacenterOrRegion(apiClient *compute.Client, datacenterID string) ([]compute.NetworkDomain, error) {
+	if datacenterID != "" {
+		return listNetworkDomains(apiClient, datacenterID)
+	}
+
+	var networkDomains []compute.NetworkDomain
+
+	page := compute.DefaultPaging()
+	page.PageSize = listPageSize
+	for {
+		result, err := apiClient.ListNetworkDomains(page)
+		if err != nil {
+			return nil, err
+		}
+		if result.IsEmpty() {
+			break
+		}
+
+		networkDomains = append(networkDomains, result.Domains...)
+
+		page.Next()
+	}
+
+	return networkDomains, nil
+}
@@ -0,0 +1,258 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// Load-balancer resources must be removed in dependency order: virtual listeners (which refer to pools and SSL offload profiles),
+// then SSL offload profiles (which refer to SSL domain certificates and certificate chains), then pools (which refer to nodes),
+// then nodes, and finally SSL domain certificates and certificate chains.
+//
+// Health monitors and persistence profiles are provided by the platform; they are released along with the pools and listeners that use them.
+
+func nukeVirtualListeners(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	virtualListeners, err := listVirtualListeners(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, virtualListener := range virtualListeners {
+		action := beginAction(logEvent{Stage: "virtuallisteners", ResourceType: "virtualListener", ResourceID: virtualListener.ID, ResourceName: virtualListener.Name, Action: "delete"},
+			"Deleting virtual listener '%s' ('%s', %s:%d)...",
+			virtualListener.Name,
+			virtualListener.ID,
+			virtualListener.ListenerIPAddress,
+			virtualListener.Port,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteVirtualListener(virtualListener.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("Virtual listener '%s' ('%s') has already been deleted.", virtualListener.Name, virtualListener.ID)
+
+			continue
+		}
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Deleted virtual listener '%s' ('%s', %s:%d).",
+			virtualListener.Name,
+			virtualListener.ID,
+			virtualListener.ListenerIPAddress,
+			virtualListener.Port,
+		)
+	}
+
+	return nil
+}
+
+func nukeSSLOffloadProfiles(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	sslOffloadProfiles, err := listSSLOffloadProfiles(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, sslOffloadProfile := range sslOffloadProfiles {
+		action := beginAction(logEvent{Stage: "ssloffloadprofiles", ResourceType: "sslOffloadProfile", ResourceID: sslOffloadProfile.ID, ResourceName: sslOffloadProfile.Name, Action: "delete"},
+			"Deleting SSL offload profile '%s' ('%s')...",
+			sslOffloadProfile.Name,
+			sslOffloadProfile.ID,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteSSLOffloadProfile(sslOffloadProfile.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("SSL offload profile '%s' ('%s') has already been deleted.", sslOffloadProfile.Name, sslOffloadProfile.ID)
+
+			continue
+		}
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Deleted SSL offload profile '%s' ('%s').",
+			sslOffloadProfile.Name,
+			sslOffloadProfile.ID,
+		)
+	}
+
+	return nil
+}
+
+func nukeVIPPools(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	vipPools, err := listVIPPools(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, vipPool := range vipPools {
+		action := beginAction(logEvent{Stage: "vippools", ResourceType: "vipPool", ResourceID: vipPool.ID, ResourceName: vipPool.Name, Action: "delete"},
+			"Deleting VIP pool '%s' ('%s')...",
+			vipPool.Name,
+			vipPool.ID,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteVIPPool(vipPool.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("VIP pool '%s' ('%s') has already been deleted.", vipPool.Name, vipPool.ID)
+
+			continue
+		}
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Deleted VIP pool '%s' ('%s').",
+			vipPool.Name,
+			vipPool.ID,
+		)
+	}
+
+	return nil
+}
+
+func nukeVIPNodes(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	vipNodes, err := listVIPNodes(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, vipNode := range vipNodes {
+		action := beginAction(logEvent{Stage: "vipnodes", ResourceType: "vipNode", ResourceID: vipNode.ID, ResourceName: vipNode.Name, Action: "delete"},
+			"Deleting VIP node '%s' ('%s')...",
+			vipNode.Name,
+			vipNode.ID,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteVIPNode(vipNode.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("VIP node '%s' ('%s') has already been deleted.", vipNode.Name, vipNode.ID)
+
+			continue
+		}
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Deleted VIP node '%s' ('%s').",
+			vipNode.Name,
+			vipNode.ID,
+		)
+	}
+
+	return nil
+}
+
+func nukeSSLCertificates(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	sslDomainCertificates, err := listSSLDomainCertificates(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, sslDomainCertificate := range sslDomainCertificates {
+		action := beginAction(logEvent{Stage: "sslcertificates", ResourceType: "sslDomainCertificate", ResourceID: sslDomainCertificate.ID, ResourceName: sslDomainCertificate.Name, Action: "delete"},
+			"Deleting SSL domain certificate '%s' ('%s')...",
+			sslDomainCertificate.Name,
+			sslDomainCertificate.ID,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteSSLDomainCertificate(sslDomainCertificate.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("SSL domain certificate '%s' ('%s') has already been deleted.", sslDomainCertificate.Name, sslDomainCertificate.ID)
+
+			continue
+		}
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Deleted SSL domain certificate '%s' ('%s').",
+			sslDomainCertificate.Name,
+			sslDomainCertificate.ID,
+		)
+	}
+
+	sslCertificateChains, err := listSSLCertificateChains(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, sslCertificateChain := range sslCertificateChains {
+		action := beginAction(logEvent{Stage: "sslcertificates", ResourceType: "sslCertificateChain", ResourceID: sslCertificateChain.ID, ResourceName: sslCertificateChain.Name, Action: "delete"},
+			"Deleting SSL certificate chain '%s' ('%s')...",
+			sslCertificateChain.Name,
+			sslCertificateChain.ID,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteSSLCertificateChain(sslCertificateChain.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("SSL certificate chain '%s' ('%s') has already been deleted.", sslCertificateChain.Name, sslCertificateChain.ID)
+
+			continue
+		}
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Deleted SSL certificate chain '%s' ('%s').",
+			sslCertificateChain.Name,
+			sslCertificateChain.ID,
+		)
+	}
+
+	return nil
+}
+
+// Count the SSL domain certificates and certificate chains in the target network domain.
+func countSSLCertificates(apiClient *compute.Client, networkDomainID string) (int, error) {
+	sslDomainCertificates, err := listSSLDomainCertificates(apiClient, networkDomainID)
+	if err != nil {
+		return 0, err
+	}
+
+	sslCertificateChains, err := listSSLCertificateChains(apiClient, networkDomainID)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(sslDomainCertificates) + len(sslCertificateChains), nil
+}
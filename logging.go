@@ -0,0 +1,249 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels (used in structured log events).
+const (
+	logLevelDebug = "debug"
+	logLevelInfo  = "info"
+	logLevelError = "error"
+)
+
+// Emit structured (JSON) log events instead of free-form text?
+var structuredLogging = false
+
+// Where human-readable output (plans, prompts, reports, and summaries) is written.
+//
+// This is STDERR when emitting structured log events, so that STDOUT only contains JSON.
+var console io.Writer = os.Stdout
+
+// Serialises writes of structured log events.
+var logEventLock = &sync.Mutex{}
+
+// A logEvent is a single structured log event (emitted as one line of JSON when --log-format=json).
+type logEvent struct {
+	Time         string  `json:"time"`
+	Level        string  `json:"level"`
+	Stage        string  `json:"stage,omitempty"`
+	ResourceType string  `json:"resourceType,omitempty"`
+	ResourceID   string  `json:"resourceId,omitempty"`
+	ResourceName string  `json:"resourceName,omitempty"`
+	Action       string  `json:"action,omitempty"`
+	Status       string  `json:"status,omitempty"`
+	Duration     float64 `json:"durationSeconds,omitempty"`
+	Message      string  `json:"message"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// Write a structured log event to STDOUT.
+func writeLogEvent(event logEvent) {
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	logEventLock.Lock()
+	defer logEventLock.Unlock()
+
+	os.Stdout.Write(append(data, '\n'))
+}
+
+// Switch the program's loggers over to emitting structured log events.
+//
+// Messages written to the program's loggers become events with no resource fields ("info" for logger, "debug" for log).
+func enableStructuredLogging(verbose bool) {
+	structuredLogging = true
+	console = os.Stderr
+
+	logger.SetOutput(&logEventWriter{Level: logLevelInfo})
+
+	log.SetPrefix("")
+	if verbose {
+		log.SetOutput(&logEventWriter{Level: logLevelDebug})
+	} else {
+		log.SetOutput(ioutil.Discard)
+	}
+}
+
+// logEventWriter is an io.Writer that converts each message written by a log.Logger into a structured log event.
+type logEventWriter struct {
+	Level string
+}
+
+var _ io.Writer = &logEventWriter{}
+
+// Write converts the message into a structured log event.
+func (writer *logEventWriter) Write(message []byte) (int, error) {
+	writeLogEvent(logEvent{
+		Level:   writer.Level,
+		Message: strings.TrimRight(string(message), "\n"),
+	})
+
+	return len(message), nil
+}
+
+// Log an error that caused the program to fail.
+func logError(err error) {
+	if structuredLogging {
+		writeLogEvent(logEvent{
+			Level:   logLevelError,
+			Message: err.Error(),
+			Error:   err.Error(),
+		})
+
+		return
+	}
+
+	logger.Printf("Error: %s", err)
+}
+
+// An actionLog tracks an action (e.g. deleting a NAT rule) performed on a resource, so that its outcome and duration can be logged.
+type actionLog struct {
+	Event   logEvent
+	Started time.Time
+	Span    *span
+}
+
+// Log the start of an action on a resource.
+//
+// In text mode, the message (if any) is written to the program's logger; in structured mode, a "started" event is emitted.
+func beginAction(event logEvent, format string, args ...interface{}) *actionLog {
+	return beginChildAction(nil, event, format, args...)
+}
+
+// Log the start of an action on a resource, whose trace span is a child of the specified span (if nil, the span for the current stage).
+func beginChildAction(parentSpan *span, event logEvent, format string, args ...interface{}) *actionLog {
+	message := fmt.Sprintf(format, args...)
+	action := &actionLog{
+		Event:   event,
+		Started: time.Now(),
+	}
+	if event.ResourceType != "" {
+		action.Span = tracer.StartSpan(parentSpan, event.Action+" "+event.ResourceType, map[string]string{
+			"nifo.stage":         event.Stage,
+			"nifo.action":        event.Action,
+			"nifo.resource.type": event.ResourceType,
+			"nifo.resource.id":   event.ResourceID,
+			"nifo.resource.name": event.ResourceName,
+		})
+		progress.ActionStarted(action)
+	}
+
+	if !structuredLogging {
+		if message != "" {
+			logger.Print(message)
+		}
+
+		return action
+	}
+
+	event.Level = logLevelInfo
+	event.Status = "started"
+	event.Message = message
+	writeLogEvent(event)
+
+	return action
+}
+
+// Log the successful completion of the action.
+func (action *actionLog) Complete(format string, args ...interface{}) {
+	action.record(outcomeSucceeded, nil)
+
+	message := fmt.Sprintf(format, args...)
+	if !structuredLogging {
+		if message != "" {
+			logger.Print(message)
+		}
+
+		return
+	}
+
+	event := action.Event
+	event.Level = logLevelInfo
+	event.Status = "completed"
+	event.Duration = time.Since(action.Started).Seconds()
+	event.Message = message
+	writeLogEvent(event)
+}
+
+// Log the failure of the action.
+//
+// In text mode, nothing is logged (the error is returned to, and reported by, the caller).
+func (action *actionLog) Fail(err error) {
+	action.record(outcomeFailed, err)
+
+	if !structuredLogging {
+		return
+	}
+
+	event := action.Event
+	event.Level = logLevelError
+	event.Status = "failed"
+	event.Duration = time.Since(action.Started).Seconds()
+	event.Message = err.Error()
+	event.Error = err.Error()
+	writeLogEvent(event)
+}
+
+// Log that the action was unnecessary because the resource no longer exists (e.g. it was deleted by someone else).
+func (action *actionLog) AlreadyDeleted(format string, args ...interface{}) {
+	action.record(outcomeAlreadyDeleted, nil)
+
+	message := fmt.Sprintf(format, args...)
+	if !structuredLogging {
+		logger.Print(message)
+
+		return
+	}
+
+	event := action.Event
+	event.Level = logLevelInfo
+	event.Status = outcomeAlreadyDeleted
+	event.Duration = time.Since(action.Started).Seconds()
+	event.Message = message
+	writeLogEvent(event)
+}
+
+// Record the outcome of the action in the progress display and the report for the current run (if any).
+//
+// The API calls made by the action are audited separately (see apiCall), since an action may also involve waiting for them to complete.
+func (action *actionLog) record(outcome string, err error) {
+	if action.Event.ResourceType == "" {
+		return
+	}
+
+	action.Span.Finish(err)
+	metrics.RecordAction(action.Event, outcome, err)
+	progress.ActionEnded(action, err)
+	if currentReport != nil {
+		currentReport.RecordAction(action.Event, time.Since(action.Started), outcome, err)
+	}
+}
@@ -0,0 +1,115 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Capture the structured log events emitted by the specified function.
+func captureLogEvents(t *testing.T, emit func()) []logEvent {
+	file, err := ioutil.TempFile("", "nifo-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	stdout := os.Stdout
+	os.Stdout = file
+	structuredLogging = true
+	defer func() {
+		os.Stdout = stdout
+		structuredLogging = false
+	}()
+
+	emit()
+
+	_, err = file.Seek(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []logEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event logEvent
+		err = json.Unmarshal(scanner.Bytes(), &event)
+		if err != nil {
+			t.Fatalf("Log event is not valid JSON: %s", scanner.Text())
+		}
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestActionLogEvents(t *testing.T) {
+	events := captureLogEvents(t, func() {
+		action := beginAction(logEvent{Stage: "natrules", ResourceType: "natRule", ResourceID: "rule1", Action: "delete"},
+			"Deleting NAT rule '%s'...", "rule1",
+		)
+		action.Complete("Deleted NAT rule '%s'.", "rule1")
+
+		action = beginAction(logEvent{Stage: "natrules", ResourceType: "natRule", ResourceID: "rule2", Action: "delete"}, "")
+		action.Fail(errors.New("boom"))
+	})
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 log events, but got %d: %+v", len(events), events)
+	}
+
+	expected := []struct {
+		ResourceID string
+		Status     string
+		Level      string
+		Message    string
+	}{
+		{"rule1", "started", logLevelInfo, "Deleting NAT rule 'rule1'..."},
+		{"rule1", "completed", logLevelInfo, "Deleted NAT rule 'rule1'."},
+		{"rule2", "started", logLevelInfo, ""},
+		{"rule2", "failed", logLevelError, "boom"},
+	}
+	for index, event := range events {
+		if event.Stage != "natrules" || event.ResourceType != "natRule" || event.Action != "delete" || event.Time == "" {
+			t.Errorf("Event %d is missing resource fields: %+v", index, event)
+		}
+		if event.ResourceID != expected[index].ResourceID || event.Status != expected[index].Status || event.Level != expected[index].Level || event.Message != expected[index].Message {
+			t.Errorf("Event %d: expected %+v, but got %+v", index, expected[index], event)
+		}
+	}
+	if events[3].Error != "boom" {
+		t.Errorf("Expected the failed event to include the error, but got %+v", events[3])
+	}
+}
+
+func TestLogEventWriter(t *testing.T) {
+	events := captureLogEvents(t, func() {
+		writer := &logEventWriter{Level: logLevelDebug}
+		writer.Write([]byte("Something happened.\n"))
+	})
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 log event, but got %d", len(events))
+	}
+	if events[0].Level != logLevelDebug || events[0].Message != "Something happened." || events[0].Stage != "" {
+		t.Errorf("Unexpected log event: %+v", events[0])
+	}
+}
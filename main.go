@@ -18,14 +18,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
 )
 
 var logger = log.New(os.Stdout, "", 0)
 
+// Shared so that input buffered while reading one response is not lost when reading the next.
+var stdin = bufio.NewReader(os.Stdin)
+
 func main() {
 	log.SetPrefix("[VERBOSE] ")
 	log.SetFlags(0) // No date / time prefix.
@@ -47,39 +53,219 @@ func main() {
 	} else {
 		log.SetOutput(ioutil.Discard)
 	}
+	if options.LogFormat == "json" {
+		enableStructuredLogging(options.Verbose)
+	} else if !options.NoProgress && isTerminal(os.Stdout) {
+		enableProgressDisplay(options.Verbose)
+	}
+
+	if options.TraceFile != "" || options.TraceEndpoint != "" {
+		enableTracing()
+	}
+
+	err := openAuditLog(options)
+	if err == nil {
+		err = runCommand(options)
+		audit.RunFinished(err)
+	}
+	showSummary()
+	if err != nil && err != errNotConfirmed {
+		logError(err)
+	}
+
+	os.Exit(exitCodeForError(err))
+}
+
+// Run the selected command.
+func runCommand(options programOptions) error {
+	if options.Command == "janitor" {
+		return janitor(options) // Creates its own clients (one per target region).
+	}
+	if options.Command == "audit verify" {
+		return verifyAuditLog(options)
+	}
 
 	apiClient, err := options.CreateClient()
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		return err
+	}
+	err = verifyCredentials(apiClient)
+	if err != nil {
+		return err
+	}
+
+	switch options.Command {
+	case "", "strip":
+		networkDomain, err := resolveNetworkDomain(apiClient, options)
+		if _, isMissing := err.(*networkDomainNotFoundError); isMissing && options.IgnoresMissing() {
+			logger.Printf("%s Nothing to do.", err)
+
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if options.Command == "strip" {
+			return strip(apiClient, networkDomain, options)
+		}
+
+		return runNuke(apiClient, networkDomain, options)
+	case "restore":
+		return restore(apiClient, options)
+	case "reap":
+		return reap(apiClient, options)
+	case "extend":
+		return extend(apiClient, options)
+	case "reclaim-ips":
+		return reclaimIPs(apiClient, options)
+	default:
+		return &badOptionsError{
+			Err: fmt.Errorf("Unknown command '%s'.", options.Command),
+		}
+	}
+}
+
+// Nuke the target network domain (or run the selected stages against it), after displaying the plan, checking for external
+// references to its public IP addresses, and asking the user to confirm.
+func runNuke(apiClient *compute.Client, networkDomain *compute.NetworkDomain, options programOptions) error {
+	audit.RecordTarget(networkDomain)
+
+	stages, err := options.Stages()
+	if err != nil {
+		return err
+	}
+	err = checkStageDependencies(apiClient, networkDomain.ID, stages, options)
+	if err != nil {
+		return err
+	}
+
+	err = showPlan(apiClient, networkDomain, stages, options)
+	if err != nil {
+		return err
+	}
+
+	var estimate *costEstimate
+	if options.RateCard != "" {
+		options.Rates, err = loadRateCard(options.RateCard, networkDomain.DatacenterID)
+		if err != nil {
+			return err
+		}
+		estimate, err = estimateMonthlyCost(apiClient, networkDomain.ID, stages, options.Rates, options)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(console, "Estimated monthly cost of the resources to be destroyed: %s.\n", estimate)
+	}
+
+	if hasStage(stages, "publicips") {
+		externalReferences, err := findExternalReferences(apiClient, networkDomain)
+		if err != nil {
+			return err
+		}
+		for _, externalReference := range externalReferences {
+			fmt.Fprintf(console, "WARNING - %s.\n", externalReference)
+		}
+		if len(externalReferences) > 0 && !options.IgnoreExternalReferences {
+			fmt.Fprintf(console, "%d resource(s) outside network domain '%s' refer to its public IP addresses; use --ignore-external-references to nuke it anyway.\n",
+				len(externalReferences),
+				networkDomain.Name,
+			)
+
+			return errExternalReferences
+		}
+	}
+
+	err = confirmStages(networkDomain, stages, estimate, options)
+	if err != nil {
+		return err
 	}
-	networkDomain, err := resolveNetworkDomain(apiClient, options)
+
+	inventoryFile, err := captureAndWriteInventory(apiClient, networkDomain, options)
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		return err
+	}
+
+	report := newRunReport(networkDomain.ID, options.NetworkDomain)
+	report.InventoryFile = inventoryFile
+	report.EstimatedMonthlySavings = estimate
+
+	return nuke(apiClient, networkDomain.ID, stages, report, options)
+}
+
+// Ask the user to confirm that they want to run the specified stages against the target network domain (unless --force
+// was specified).
+//
+// Returns errNotConfirmed if the user does not confirm.
+func confirmStages(networkDomain *compute.NetworkDomain, stages []nukeStage, estimate *costEstimate, options programOptions) error {
+	if options.Force {
+		return nil
 	}
 
-	if !options.Force {
-		fmt.Printf("WARNING - about to delete network domain '%s' (Id = '%s') in datacenter '%s'. Are you sure you want to proceed?\n",
+	if isFullNuke(stages) {
+		fmt.Fprintf(console, "WARNING - about to delete network domain '%s' (Id = '%s') in datacenter '%s'. Are you sure you want to proceed?\n",
 			networkDomain.Name,
 			networkDomain.ID,
 			networkDomain.DatacenterID,
 		)
-		fmt.Printf("Type yes to continue: ")
-		stdin := bufio.NewReader(os.Stdin)
-		confirmation, _, err := stdin.ReadLine()
-		if err != nil {
-			log.Println(err)
-			os.Exit(1)
-		}
-		if string(confirmation) != "yes" {
-			os.Exit(2)
-		}
+	} else {
+		fmt.Fprintf(console, "WARNING - about to run stages '%s' against network domain '%s' (Id = '%s') in datacenter '%s'. Are you sure you want to proceed?\n",
+			stageNames(stages),
+			networkDomain.Name,
+			networkDomain.ID,
+			networkDomain.DatacenterID,
+		)
+	}
+	if estimate != nil {
+		fmt.Fprintf(console, "This will save an estimated %s per month.\n", formatCost(estimate.Currency, estimate.Total))
+	}
+	confirmed, err := confirm()
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return errNotConfirmed
 	}
 
-	err = nuke(apiClient, networkDomain.ID)
+	return nil
+}
+
+// Capture and write an inventory of the target network domain, returning the inventory file's path (or an empty string
+// if --no-inventory was specified).
+func captureAndWriteInventory(apiClient *compute.Client, networkDomain *compute.NetworkDomain, options programOptions) (string, error) {
+	if options.NoInventory {
+		return "", nil
+	}
+
+	domainInventory, err := captureInventory(apiClient, options.Region, networkDomain)
 	if err != nil {
-		log.Println(err)
-		os.Exit(1)
+		return "", err
 	}
+
+	inventoryFile, err := writeInventory(domainInventory, options.InventoryDirectory, options.InventoryFormat)
+	if err != nil {
+		return "", err
+	}
+
+	summary.AddInventoryFile(networkDomain.Name, inventoryFile)
+
+	return inventoryFile, nil
+}
+
+// Returned when resources outside the target network domain refer to its public IP addresses.
+var errExternalReferences = errors.New("Resources outside the target network domain refer to its public IP addresses.")
+
+// Returned when the user does not confirm that they want to proceed.
+var errNotConfirmed = errors.New("Operation was not confirmed.")
+
+// Ask the user to confirm that they want to proceed.
+func confirm() (bool, error) {
+	fmt.Fprintf(console, "Type yes to continue: ")
+	confirmation, _, err := stdin.ReadLine()
+	if err != nil {
+		return false, err
+	}
+
+	return string(confirmation) == "yes", nil
 }
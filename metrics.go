@@ -0,0 +1,317 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// Metrics for the current process (exposed in Prometheus text format by the janitor's metrics endpoint).
+var metrics = newMetricsRegistry()
+
+// The upper bounds (in seconds) of the buckets in the API call duration histogram.
+var apiCallDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// metricsRegistry collects the program's metrics.
+//
+// It is safe to update from multiple goroutines.
+type metricsRegistry struct {
+	lock sync.Mutex
+
+	domainsDeleted      float64
+	resourcesDeleted    map[string]float64
+	failures            map[string]float64
+	retries             map[string]float64
+	apiCallDurations    map[string]*histogram
+	cycles              float64
+	lastCycle           time.Time
+	lastSuccessfulCycle time.Time
+}
+
+// A histogram counts observations in cumulative buckets.
+type histogram struct {
+	Counts []float64 // One per bucket in apiCallDurationBuckets.
+	Count  float64
+	Sum    float64
+}
+
+// Create a new metrics registry.
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{
+		resourcesDeleted: make(map[string]float64),
+		failures:         make(map[string]float64),
+		retries:          make(map[string]float64),
+		apiCallDurations: make(map[string]*histogram),
+	}
+}
+
+// RecordAction records the outcome of an action performed on a resource.
+func (registry *metricsRegistry) RecordAction(event logEvent, outcome string, err error) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if outcome == outcomeSucceeded && (event.Action == "delete" || event.Action == "unreserve") {
+		registry.resourcesDeleted[event.ResourceType]++
+	}
+	if outcome == outcomeFailed {
+		registry.failures[errorCode(err)]++
+	}
+}
+
+// RecordAPICall records the duration and response code of a CloudControl API call that changes a resource.
+func (registry *metricsRegistry) RecordAPICall(resourceType string, action string, duration time.Duration, err error) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	code := "OK"
+	if err != nil {
+		code = errorCode(err)
+	}
+
+	key := resourceType + "\x00" + action + "\x00" + code
+	durations, ok := registry.apiCallDurations[key]
+	if !ok {
+		durations = &histogram{
+			Counts: make([]float64, len(apiCallDurationBuckets)),
+		}
+		registry.apiCallDurations[key] = durations
+	}
+
+	seconds := duration.Seconds()
+	for index, upperBound := range apiCallDurationBuckets {
+		if seconds <= upperBound {
+			durations.Counts[index]++
+		}
+	}
+	durations.Count++
+	durations.Sum += seconds
+}
+
+// AddDomainDeleted records that a network domain was deleted.
+func (registry *metricsRegistry) AddDomainDeleted() {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.domainsDeleted++
+}
+
+// AddRetry records that an operation was retried.
+func (registry *metricsRegistry) AddRetry(operation string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.retries[operation]++
+}
+
+// CycleCompleted records the completion of a janitor cycle.
+func (registry *metricsRegistry) CycleCompleted(successful bool) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	registry.cycles++
+	registry.lastCycle = time.Now()
+	if successful {
+		registry.lastSuccessfulCycle = registry.lastCycle
+	}
+}
+
+// LastCycle returns the time that the most recent janitor cycle completed (zero if none has completed).
+func (registry *metricsRegistry) LastCycle() time.Time {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	return registry.lastCycle
+}
+
+// WriteTo writes the metrics in Prometheus text exposition format.
+func (registry *metricsRegistry) WriteTo(buffer *bytes.Buffer) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	writeMetricHeader(buffer, "nifo_network_domains_deleted_total", "counter", "Network domains deleted.")
+	fmt.Fprintf(buffer, "nifo_network_domains_deleted_total %s\n", formatMetricValue(registry.domainsDeleted))
+
+	writeMetricHeader(buffer, "nifo_resources_deleted_total", "counter", "Resources deleted, by resource type.")
+	for _, resourceType := range sortedKeys(registry.resourcesDeleted) {
+		fmt.Fprintf(buffer, "nifo_resources_deleted_total{type=\"%s\"} %s\n",
+			escapeLabelValue(resourceType),
+			formatMetricValue(registry.resourcesDeleted[resourceType]),
+		)
+	}
+
+	writeMetricHeader(buffer, "nifo_failures_total", "counter", "Failed actions, by error code (the CloudControl API response code, TIMEOUT, or OTHER).")
+	for _, code := range sortedKeys(registry.failures) {
+		fmt.Fprintf(buffer, "nifo_failures_total{code=\"%s\"} %s\n",
+			escapeLabelValue(code),
+			formatMetricValue(registry.failures[code]),
+		)
+	}
+
+	writeMetricHeader(buffer, "nifo_retries_total", "counter", "Retried operations, by operation.")
+	for _, operation := range sortedKeys(registry.retries) {
+		fmt.Fprintf(buffer, "nifo_retries_total{operation=\"%s\"} %s\n",
+			escapeLabelValue(operation),
+			formatMetricValue(registry.retries[operation]),
+		)
+	}
+
+	writeMetricHeader(buffer, "nifo_api_call_duration_seconds", "histogram", "Duration of CloudControl API calls that change resources, by resource type, action, and response code.")
+	var keys []string
+	for key := range registry.apiCallDurations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyParts := strings.SplitN(key, "\x00", 3)
+		labels := fmt.Sprintf("type=\"%s\",action=\"%s\",code=\"%s\"",
+			escapeLabelValue(keyParts[0]),
+			escapeLabelValue(keyParts[1]),
+			escapeLabelValue(keyParts[2]),
+		)
+
+		durations := registry.apiCallDurations[key]
+		for index, upperBound := range apiCallDurationBuckets {
+			fmt.Fprintf(buffer, "nifo_api_call_duration_seconds_bucket{%s,le=\"%s\"} %s\n",
+				labels,
+				formatMetricValue(upperBound),
+				formatMetricValue(durations.Counts[index]),
+			)
+		}
+		fmt.Fprintf(buffer, "nifo_api_call_duration_seconds_bucket{%s,le=\"+Inf\"} %s\n", labels, formatMetricValue(durations.Count))
+		fmt.Fprintf(buffer, "nifo_api_call_duration_seconds_sum{%s} %s\n", labels, formatMetricValue(durations.Sum))
+		fmt.Fprintf(buffer, "nifo_api_call_duration_seconds_count{%s} %s\n", labels, formatMetricValue(durations.Count))
+	}
+
+	writeMetricHeader(buffer, "nifo_janitor_cycles_total", "counter", "Janitor cycles completed.")
+	fmt.Fprintf(buffer, "nifo_janitor_cycles_total %s\n", formatMetricValue(registry.cycles))
+
+	writeMetricHeader(buffer, "nifo_janitor_last_successful_cycle_timestamp_seconds", "gauge", "When the last janitor cycle without errors completed (Unix time; 0 if none has).")
+	lastSuccessfulCycle := 0.0
+	if !registry.lastSuccessfulCycle.IsZero() {
+		lastSuccessfulCycle = float64(registry.lastSuccessfulCycle.Unix())
+	}
+	fmt.Fprintf(buffer, "nifo_janitor_last_successful_cycle_timestamp_seconds %s\n", formatMetricValue(lastSuccessfulCycle))
+}
+
+// Serve the janitor's metrics (/metrics) and health (/healthz) endpoints.
+//
+// An error is returned if the listen address cannot be bound (the endpoints are then served in the background).
+func serveJanitorMetrics(listenAddress string, interval time.Duration) error {
+	listener, err := net.Listen("tcp", listenAddress)
+	if err != nil {
+		return fmt.Errorf("Unable to serve metrics on '%s' (%s).", listenAddress, err)
+	}
+
+	logger.Printf("Serving metrics on '%s'...", listener.Addr())
+
+	go func() {
+		err := http.Serve(listener, newJanitorMetricsHandler(metrics, interval))
+		if err != nil {
+			logger.Printf("Metrics endpoint failed: %s", err)
+		}
+	}()
+
+	return nil
+}
+
+// Create a handler for the janitor's metrics (/metrics) and health (/healthz) endpoints.
+//
+// The janitor is considered ready once it has completed a cycle, for as long as cycles keep completing (within 3 intervals of each other).
+func newJanitorMetricsHandler(registry *metricsRegistry, interval time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(response http.ResponseWriter, request *http.Request) {
+		buffer := &bytes.Buffer{}
+		registry.WriteTo(buffer)
+
+		response.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		response.Write(buffer.Bytes())
+	})
+	mux.HandleFunc("/healthz", func(response http.ResponseWriter, request *http.Request) {
+		lastCycle := registry.LastCycle()
+		if lastCycle.IsZero() {
+			http.Error(response, "Waiting for the first janitor cycle to complete.", http.StatusServiceUnavailable)
+
+			return
+		}
+		if time.Since(lastCycle) > 3*interval {
+			http.Error(response, fmt.Sprintf("No janitor cycle has completed since %s.", lastCycle.UTC().Format(time.RFC3339)), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		fmt.Fprintln(response, "OK")
+	})
+
+	return mux
+}
+
+// Determine the error code for a failure (used to label metrics).
+//
+// This is the response code returned by the CloudControl API (e.g. RESOURCE_BUSY) or, if there is none, its HTTP status
+// (e.g. HTTP_503); TIMEOUT if waiting for CloudControl timed out; otherwise, OTHER (e.g. a network error).
+func errorCode(err error) string {
+	if _, isTimeout := err.(*compute.OperationTimeoutError); isTimeout {
+		return "TIMEOUT"
+	}
+
+	if apiError, isAPIError := err.(*compute.APIError); isAPIError {
+		if apiError.Response != nil && apiError.Response.GetResponseCode() != "" {
+			return apiError.Response.GetResponseCode()
+		}
+
+		return fmt.Sprintf("HTTP_%d", apiError.HTTPStatus)
+	}
+
+	return "OTHER"
+}
+
+func writeMetricHeader(buffer *bytes.Buffer, name string, metricType string, help string) {
+	fmt.Fprintf(buffer, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(buffer, "# TYPE %s %s\n", name, metricType)
+}
+
+func formatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Escape a label value (backslashes, double-quotes, and line feeds must be escaped).
+func escapeLabelValue(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `"`, `\"`, -1)
+
+	return strings.Replace(value, "\n", `\n`, -1)
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
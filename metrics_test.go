@@ -0,0 +1,147 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// Get the specified path from a test server, returning the response status code and body.
+func getTestEndpoint(t *testing.T, server *httptest.Server, path string) (int, string) {
+	response, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return response.StatusCode, string(body)
+}
+
+func TestMetricsExposition(t *testing.T) {
+	registry := newMetricsRegistry()
+	registry.RecordAction(logEvent{ResourceType: "natRule", Action: "delete"}, outcomeSucceeded, nil)
+	registry.RecordAction(logEvent{ResourceType: "natRule", Action: "delete"}, outcomeSucceeded, nil)
+	registry.RecordAction(logEvent{ResourceType: "server", Action: "power-off"}, outcomeSucceeded, nil)
+	registry.RecordAction(logEvent{ResourceType: "server", Action: "delete"}, outcomeFailed, &compute.OperationTimeoutError{})
+	registry.RecordAPICall("natRule", "delete", 300*time.Millisecond, nil)
+	registry.RecordAPICall("natRule", "delete", 2*time.Second, nil)
+	registry.RecordAPICall("server", "delete", 100*time.Millisecond, &compute.APIError{
+		Message:    "Server is busy.",
+		HTTPStatus: http.StatusBadRequest,
+		Response:   &compute.APIResponseV2{ResponseCode: compute.ResultCodeResourceBusy},
+	})
+	registry.AddRetry("notification")
+	registry.AddDomainDeleted()
+
+	server := httptest.NewServer(newJanitorMetricsHandler(registry, time.Hour))
+	defer server.Close()
+
+	statusCode, body := getTestEndpoint(t, server, "/metrics")
+	if statusCode != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, statusCode)
+	}
+
+	expectedLines := []string{
+		"# TYPE nifo_network_domains_deleted_total counter",
+		"nifo_network_domains_deleted_total 1",
+		`nifo_resources_deleted_total{type="natRule"} 2`,
+		`nifo_failures_total{code="TIMEOUT"} 1`,
+		`nifo_retries_total{operation="notification"} 1`,
+		"# TYPE nifo_api_call_duration_seconds histogram",
+		`nifo_api_call_duration_seconds_bucket{type="natRule",action="delete",code="OK",le="0.25"} 0`,
+		`nifo_api_call_duration_seconds_bucket{type="natRule",action="delete",code="OK",le="0.5"} 1`,
+		`nifo_api_call_duration_seconds_bucket{type="natRule",action="delete",code="OK",le="2.5"} 2`,
+		`nifo_api_call_duration_seconds_bucket{type="natRule",action="delete",code="OK",le="+Inf"} 2`,
+		`nifo_api_call_duration_seconds_sum{type="natRule",action="delete",code="OK"} 2.3`,
+		`nifo_api_call_duration_seconds_count{type="natRule",action="delete",code="OK"} 2`,
+		`nifo_api_call_duration_seconds_count{type="server",action="delete",code="RESOURCE_BUSY"} 1`,
+		"nifo_janitor_cycles_total 0",
+		"nifo_janitor_last_successful_cycle_timestamp_seconds 0",
+	}
+	lines := strings.Split(body, "\n")
+	for _, expectedLine := range expectedLines {
+		found := false
+		for _, line := range lines {
+			if line == expectedLine {
+				found = true
+
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected metrics to include '%s':\n%s", expectedLine, body)
+		}
+	}
+	if strings.Contains(body, `type="server",action="power-off"`) {
+		t.Errorf("Expected actions without API calls to have no API call metrics:\n%s", body)
+	}
+}
+
+func TestMetricsHealth(t *testing.T) {
+	registry := newMetricsRegistry()
+	server := httptest.NewServer(newJanitorMetricsHandler(registry, time.Hour))
+	defer server.Close()
+
+	statusCode, body := getTestEndpoint(t, server, "/healthz")
+	if statusCode != http.StatusServiceUnavailable || !strings.Contains(body, "Waiting for the first janitor cycle") {
+		t.Errorf("Expected not ready before the first cycle, but got %d: %s", statusCode, body)
+	}
+
+	registry.CycleCompleted(false)
+	statusCode, body = getTestEndpoint(t, server, "/healthz")
+	if statusCode != http.StatusOK {
+		t.Errorf("Expected ready after a cycle (even one with errors), but got %d: %s", statusCode, body)
+	}
+
+	registry.lastCycle = time.Now().Add(-4 * time.Hour)
+	statusCode, body = getTestEndpoint(t, server, "/healthz")
+	if statusCode != http.StatusServiceUnavailable || !strings.Contains(body, "No janitor cycle has completed since") {
+		t.Errorf("Expected not ready after 3 intervals without a cycle, but got %d: %s", statusCode, body)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	testCases := []struct {
+		Err      error
+		Expected string
+	}{
+		{&compute.OperationTimeoutError{}, "TIMEOUT"},
+		{&compute.APIError{Message: "Not found.", Response: &compute.APIResponseV2{ResponseCode: compute.ResultCodeResourceNotFound}}, "RESOURCE_NOT_FOUND"},
+		{&compute.APIError{Message: "Invalid input.", Response: &compute.APIResponseV2{ResponseCode: "INVALID_INPUT_DATA"}}, "INVALID_INPUT_DATA"},
+		{&compute.APIError{Message: "Service unavailable.", HTTPStatus: http.StatusServiceUnavailable}, "HTTP_503"},
+		{fmt.Errorf("Connection refused."), "OTHER"},
+	}
+
+	for _, testCase := range testCases {
+		if actual := errorCode(testCase.Err); actual != testCase.Expected {
+			t.Errorf("errorCode(%q): expected '%s', but got '%s'", testCase.Err, testCase.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// Notification events.
+const (
+	notificationStarted        = "started"
+	notificationStageCompleted = "stage-completed"
+	notificationCompleted      = "completed"
+	notificationFailed         = "failed"
+)
+
+// The number of times a notification is sent before giving up.
+const notificationAttempts = 3
+
+// The maximum number of notifications waiting to be sent (further notifications are dropped until there is room).
+const notificationQueueSize = 32
+
+// How long to wait, once a nuke has finished, for outstanding notifications to be sent.
+const notificationFlushTimeout = 30 * time.Second
+
+// Warn (once per process) that notifications are not being signed.
+var warnUnsignedNotifications sync.Once
+
+// The environment variable containing the secret used to sign notifications.
+const notificationSecretVariable = "NIFO_NOTIFY_SECRET"
+
+// A notification is the JSON payload posted to each --notify-url.
+type notification struct {
+	Event             string         `json:"event"`
+	Time              string         `json:"time"`
+	Region            string         `json:"region"`
+	DatacenterID      string         `json:"datacenterId"`
+	NetworkDomainID   string         `json:"networkDomainId"`
+	NetworkDomainName string         `json:"networkDomainName"`
+	Operator          string         `json:"operator"`
+	LocalUser         string         `json:"localUser"`
+	ResourceCounts    map[string]int `json:"resourceCounts,omitempty"`
+	Stage             *stageReport   `json:"stage,omitempty"`
+	Outcome           string         `json:"outcome,omitempty"`
+	Report            *runReport     `json:"report,omitempty"`
+}
+
+// A queuedNotification is a notification waiting to be posted to the webhooks.
+type queuedNotification struct {
+	Event string
+	Body  []byte
+}
+
+// notifier posts notifications about the progress of a nuke to the configured webhooks.
+//
+// Notifications are posted by a background worker, so that a slow or unavailable webhook does not hold up the nuke.
+type notifier struct {
+	URLs       []string
+	Secret     string
+	Template   notification
+	Client     *http.Client
+	RetryDelay time.Duration
+
+	queue chan queuedNotification
+	done  chan struct{}
+}
+
+// Create a notifier for a nuke of the specified network domain (returns nil if no --notify-url was specified).
+func newNotifier(networkDomainID string, options programOptions) *notifier {
+	if len(options.NotifyURLs) == 0 {
+		return nil
+	}
+
+	localUser := os.Getenv("USER")
+	if currentUser, err := user.Current(); err == nil {
+		localUser = currentUser.Username
+	}
+	if hostName, err := os.Hostname(); err == nil {
+		localUser += "@" + hostName
+	}
+
+	secret := os.Getenv(notificationSecretVariable)
+	if secret == "" {
+		warnUnsignedNotifications.Do(func() {
+			logger.Printf("WARNING - the %s environment variable has not been set; notifications will not be signed.", notificationSecretVariable)
+		})
+	}
+
+	notifier := &notifier{
+		URLs:   options.NotifyURLs,
+		Secret: secret,
+		Template: notification{
+			Region:            options.Region,
+			DatacenterID:      options.Datacenter,
+			NetworkDomainID:   networkDomainID,
+			NetworkDomainName: options.NetworkDomain,
+			Operator:          os.Getenv("MCP_USER"),
+			LocalUser:         localUser,
+		},
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		RetryDelay: 1 * time.Second,
+	}
+	notifier.start()
+
+	return notifier
+}
+
+// Start the background worker that posts queued notifications.
+func (notifier *notifier) start() {
+	notifier.queue = make(chan queuedNotification, notificationQueueSize)
+	notifier.done = make(chan struct{})
+
+	go func() {
+		defer close(notifier.done)
+
+		for queued := range notifier.queue {
+			for _, url := range notifier.URLs {
+				err := notifier.post(url, queued.Event, queued.Body)
+				if err != nil {
+					logger.Printf("Failed to send '%s' notification to '%s': %s", queued.Event, url, err)
+				}
+			}
+		}
+	}()
+}
+
+// Wait (until the timeout elapses) for outstanding notifications to be sent, then stop the background worker.
+func (notifier *notifier) flush(timeout time.Duration) {
+	close(notifier.queue)
+
+	select {
+	case <-notifier.done:
+	case <-time.After(timeout):
+		logger.Printf("Timed out after %s waiting for notifications to be sent; outstanding notifications have been abandoned.", timeout)
+	}
+}
+
+// Started notifies that a nuke has started (including the number of resources each stage will process).
+func (notifier *notifier) Started(apiClient *compute.Client, stages []nukeStage, options programOptions) {
+	if notifier == nil {
+		return
+	}
+
+	resourceCounts := make(map[string]int)
+	for _, stage := range stages {
+		count, err := stage.Count(apiClient, notifier.Template.NetworkDomainID, options)
+		if err != nil {
+			log.Printf("Unable to count resources for stage '%s' (%s).", stage.Name, err)
+
+			continue
+		}
+		resourceCounts[stage.Name] = count
+	}
+
+	payload := notifier.Template
+	payload.Event = notificationStarted
+	payload.ResourceCounts = resourceCounts
+	notifier.send(payload)
+}
+
+// StageCompleted notifies that a stage has completed (or failed).
+func (notifier *notifier) StageCompleted(stage stageReport) {
+	if notifier == nil {
+		return
+	}
+
+	payload := notifier.Template
+	payload.Event = notificationStageCompleted
+	payload.Stage = &stage
+	payload.Outcome = stage.Outcome
+	notifier.send(payload)
+}
+
+// Finished notifies that a nuke has finished (including its report), and waits for outstanding notifications to be sent.
+func (notifier *notifier) Finished(report *runReport) {
+	if notifier == nil {
+		return
+	}
+
+	payload := notifier.Template
+	payload.Event = notificationCompleted
+	if report.Outcome == outcomeFailed {
+		payload.Event = notificationFailed
+	}
+	payload.Outcome = report.Outcome
+	payload.Report = report
+	notifier.send(payload)
+
+	notifier.flush(notificationFlushTimeout)
+}
+
+// Queue a notification to be posted to each webhook (failures are logged, but do not affect the nuke).
+func (notifier *notifier) send(payload notification) {
+	payload.Time = time.Now().UTC().Format(time.RFC3339)
+
+	if payload.Report != nil {
+		payload.Report.lock.Lock()
+	}
+	body, err := json.Marshal(payload)
+	if payload.Report != nil {
+		payload.Report.lock.Unlock()
+	}
+	if err != nil {
+		logger.Printf("Failed to create '%s' notification: %s", payload.Event, err)
+
+		return
+	}
+
+	select {
+	case notifier.queue <- queuedNotification{Event: payload.Event, Body: body}:
+	default:
+		logger.Printf("Dropped '%s' notification (too many notifications are waiting to be sent).", payload.Event)
+	}
+}
+
+// Post a notification to a webhook, retrying (with exponential back-off) if it fails.
+func (notifier *notifier) post(url string, event string, body []byte) (err error) {
+	retryDelay := notifier.RetryDelay
+	for attempt := 1; attempt <= notificationAttempts; attempt++ {
+		if attempt > 1 {
+			log.Printf("Retrying '%s' notification to '%s' in %s (%s)...", event, url, retryDelay, err)
+			metrics.AddRetry("notification")
+
+			time.Sleep(retryDelay)
+			retryDelay *= 2
+		}
+
+		var retry bool
+		retry, err = notifier.postOnce(url, event, body)
+		if err == nil || !retry {
+			return
+		}
+	}
+
+	return
+}
+
+// Post a notification to a webhook once (returns true if a failure is worth retrying).
+func (notifier *notifier) postOnce(url string, event string, body []byte) (retry bool, err error) {
+	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Nifo-Event", event)
+	if notifier.Secret != "" {
+		request.Header.Set("X-Nifo-Signature", "sha256="+signNotification(body, notifier.Secret))
+	}
+
+	response, err := notifier.Client.Do(request)
+	if err != nil {
+		return true, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		retry = response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+
+		return retry, fmt.Errorf("Webhook returned unexpected status '%s'.", response.Status)
+	}
+
+	return false, nil
+}
+
+// Compute the signature for a notification (a hex-encoded HMAC-SHA256 of its body).
+func signNotification(body []byte, secret string) string {
+	signature := hmac.New(sha256.New, []byte(secret))
+	signature.Write(body)
+
+	return hex.EncodeToString(signature.Sum(nil))
+}
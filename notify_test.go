@@ -0,0 +1,148 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A request received by a test webhook.
+type receivedNotification struct {
+	Event     string
+	Signature string
+	Payload   notification
+}
+
+func TestNotifierPostsSignedPayloadsAndRetries(t *testing.T) {
+	var lock sync.Mutex
+	var received []receivedNotification
+	failuresRemaining := 2
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if failuresRemaining > 0 {
+			failuresRemaining--
+			writer.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signature := request.Header.Get("X-Nifo-Signature"); signature != "sha256="+signNotification(body, "test-secret") {
+			t.Errorf("Unexpected signature '%s'", signature)
+		}
+
+		var payload notification
+		err = json.Unmarshal(body, &payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received = append(received, receivedNotification{
+			Event:     request.Header.Get("X-Nifo-Event"),
+			Signature: request.Header.Get("X-Nifo-Signature"),
+			Payload:   payload,
+		})
+	}))
+	defer server.Close()
+
+	testNotifier := &notifier{
+		URLs:   []string{server.URL},
+		Secret: "test-secret",
+		Template: notification{
+			Region:            "AU",
+			NetworkDomainID:   "domain-id",
+			NetworkDomainName: "my-domain",
+		},
+		Client:     server.Client(),
+		RetryDelay: 1 * time.Millisecond,
+	}
+	testNotifier.start()
+
+	testNotifier.StageCompleted(stageReport{Stage: "natrules", Outcome: outcomeSucceeded, Succeeded: 3})
+	report := newRunReport("domain-id", "my-domain")
+	report.Finish(nil)
+	testNotifier.Finished(report)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 notifications, but received %d", len(received))
+	}
+
+	stageCompleted := received[0]
+	if stageCompleted.Event != notificationStageCompleted || stageCompleted.Payload.Event != notificationStageCompleted {
+		t.Errorf("Expected first notification to be '%s', but got '%s' (header '%s')", notificationStageCompleted, stageCompleted.Payload.Event, stageCompleted.Event)
+	}
+	if stageCompleted.Payload.Stage == nil || stageCompleted.Payload.Stage.Stage != "natrules" || stageCompleted.Payload.Stage.Succeeded != 3 {
+		t.Errorf("Unexpected stage in notification: %+v", stageCompleted.Payload.Stage)
+	}
+	if stageCompleted.Payload.NetworkDomainID != "domain-id" || stageCompleted.Payload.Region != "AU" || stageCompleted.Payload.Time == "" {
+		t.Errorf("Unexpected notification payload: %+v", stageCompleted.Payload)
+	}
+
+	completed := received[1]
+	if completed.Event != notificationCompleted || completed.Payload.Outcome != outcomeSucceeded {
+		t.Errorf("Expected second notification to be a successful '%s', but got '%s' (outcome '%s')", notificationCompleted, completed.Event, completed.Payload.Outcome)
+	}
+	if completed.Payload.Report == nil || completed.Payload.Report.NetworkDomainName != "my-domain" {
+		t.Errorf("Expected final notification to include the report, but got %+v", completed.Payload.Report)
+	}
+}
+
+func TestNotifierDoesNotRetryClientErrors(t *testing.T) {
+	var lock sync.Mutex
+	attempts := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		attempts++
+		writer.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	testNotifier := &notifier{
+		URLs:       []string{server.URL},
+		Client:     server.Client(),
+		RetryDelay: 1 * time.Millisecond,
+	}
+
+	err := testNotifier.post(server.URL, notificationStarted, []byte("{}"))
+	if err == nil {
+		t.Error("Expected an error for a 400 response")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, but got %d", attempts)
+	}
+}
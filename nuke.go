@@ -18,78 +18,132 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
 )
 
-// Destroy the target network domain.
-func nuke(apiClient *compute.Client, networkDomainID string) error {
-	logger.Printf("Destroying network domain '%s'...", networkDomainID)
+// Destroy the target network domain (or, if only some stages are selected, the resources they cover).
+//
+// The outcome of each action is recorded in the specified report.
+func nuke(apiClient *compute.Client, networkDomainID string, stages []nukeStage, report *runReport, options programOptions) error {
+	logger.Printf("Destroying network domain '%s' (stages: %s)...", networkDomainID, stageNames(stages))
 
-	err := nukeNATRules(apiClient, networkDomainID)
-	if err != nil {
-		return err
-	}
-
-	err = nukePublicIPBlocks(apiClient, networkDomainID)
-	if err != nil {
-		return err
-	}
+	return runStages(apiClient, "nuke", networkDomainID, stages, report, options)
+}
 
-	err = nukeServers(apiClient, networkDomainID)
-	if err != nil {
-		return err
+// Run the specified stages against the target network domain as a single operation (e.g. "nuke" or "strip").
+//
+// The operation is traced, notified (see --notify-url), and reported on (see --report) as a whole, and the outcome of
+// each action is recorded in the specified report.
+func runStages(apiClient *compute.Client, operation string, networkDomainID string, stages []nukeStage, report *runReport, options programOptions) error {
+	currentReport = report
+	defer func() {
+		currentReport = nil
+	}()
+
+	notifier := newNotifier(networkDomainID, options)
+	notifier.Started(apiClient, stages, options)
+
+	rootSpan := tracer.StartTrace(operation, map[string]string{
+		"nifo.region":              options.Region,
+		"nifo.datacenter":          options.Datacenter,
+		"nifo.network_domain.id":   networkDomainID,
+		"nifo.network_domain.name": options.NetworkDomain,
+		"nifo.stages":              stageNames(stages),
+	})
+
+	progress.Start(len(stages))
+	err := nukeStages(apiClient, networkDomainID, stages, report, notifier, options)
+	progress.Stop()
+	report.Finish(err)
+	notifier.Finished(report)
+
+	rootSpan.Finish(err)
+	traceErr := tracer.Export(options.TraceFile, options.TraceEndpoint)
+	if traceErr != nil {
+		logger.Printf("Failed to export trace: %s", traceErr)
 	}
-
-	// TODO: Nuke VIP nodes, VIP pools, and virtual listeners.
-
-	err = nukeVLANs(apiClient, networkDomainID)
 	if err != nil {
-		return err
+		err = &partialFailureError{
+			NetworkDomainID: networkDomainID,
+			Err:             err,
+		}
 	}
 
-	err = nukeNetworkDomain(apiClient, networkDomainID)
-	if err != nil {
-		return err
+	showReport(report)
+	if options.Report != "" {
+		reportFile := reportFilePath(options.Report, options.NetworkDomain, options)
+		reportErr := writeReport(report, reportFile)
+		if reportErr != nil {
+			logger.Printf("Failed to write report to '%s': %s", reportFile, reportErr)
+		} else {
+			summary.AddReportFile(options.NetworkDomain, reportFile)
+		}
 	}
 
-	return nil
+	return err
 }
 
-func nukeNATRules(apiClient *compute.Client, networkDomainID string) error {
-	var natRules []compute.NATRule
-
-	page := compute.DefaultPaging()
-	page.PageSize = 20
-	for {
-		result, err := apiClient.ListNATRules(networkDomainID, page)
+// Run the selected stages (in order), stopping at the first one that fails.
+func nukeStages(apiClient *compute.Client, networkDomainID string, stages []nukeStage, report *runReport, notifier *notifier, options programOptions) error {
+	for _, stage := range stages {
+		progress.StartStage(stage.Name)
+		action := beginAction(logEvent{Stage: stage.Name, Action: "run"}, "")
+		stageSpan := tracer.StartSpan(nil, "stage "+stage.Name, map[string]string{
+			"nifo.stage": stage.Name,
+		})
+		tracer.SetStageSpan(stageSpan)
+
+		err := stage.Nuke(apiClient, networkDomainID, options)
+		tracer.SetStageSpan(nil)
+		stageSpan.Finish(err)
+		notifier.StageCompleted(
+			report.RecordStage(stage.Name, time.Since(action.Started), err),
+		)
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
-		if result.IsEmpty() {
-			break
-		}
 
-		natRules = append(natRules, result.Rules...)
+		action.Complete("")
+	}
+
+	return nil
+}
 
-		page.Next()
+func nukeNATRules(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	natRules, err := listNATRules(apiClient, networkDomainID)
+	if err != nil {
+		return err
 	}
 
 	for _, natRule := range natRules {
-		logger.Printf("Deleting NAT rule '%s' (%s -> %s)...",
+		action := beginAction(logEvent{Stage: "natrules", ResourceType: "natRule", ResourceID: natRule.ID, Action: "delete"},
+			"Deleting NAT rule '%s' (%s -> %s)...",
 			natRule.ID,
 			natRule.ExternalIPAddress,
 			natRule.InternalIPAddress,
 		)
 
+		call := action.BeginAPICall()
 		err := apiClient.DeleteNATRule(natRule.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("NAT rule '%s' has already been deleted.", natRule.ID)
+
+			continue
+		}
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
 
-		logger.Printf("Deleted NAT rule '%s' (%s -> %s).",
+		action.Complete("Deleted NAT rule '%s' (%s -> %s).",
 			natRule.ID,
 			natRule.ExternalIPAddress,
 			natRule.InternalIPAddress,
@@ -99,36 +153,33 @@ func nukeNATRules(apiClient *compute.Client, networkDomainID string) error {
 	return nil
 }
 
-func nukePublicIPBlocks(apiClient *compute.Client, networkDomainID string) error {
-	var publicIPBlocks []compute.PublicIPBlock
-
-	page := compute.DefaultPaging()
-	page.PageSize = 20
-	for {
-		result, err := apiClient.ListPublicIPBlocks(networkDomainID, page)
-		if err != nil {
-			return err
-		}
-		if result.IsEmpty() {
-			break
-		}
-
-		publicIPBlocks = append(publicIPBlocks, result.Blocks...)
-
-		page.Next()
+func nukePublicIPBlocks(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomainID)
+	if err != nil {
+		return err
 	}
 
 	for _, publicIPBlock := range publicIPBlocks {
-		logger.Printf("Deleting public IP block '%s'...",
+		action := beginAction(logEvent{Stage: "publicips", ResourceType: "publicIPBlock", ResourceID: publicIPBlock.ID, Action: "delete"},
+			"Deleting public IP block '%s'...",
 			publicIPBlock.ID,
 		)
 
+		call := action.BeginAPICall()
 		err := apiClient.RemovePublicIPBlock(publicIPBlock.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("Public IP block '%s' has already been deleted.", publicIPBlock.ID)
+
+			continue
+		}
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
 
-		logger.Printf("Deleted public IP block '%s'...",
+		action.Complete("Deleted public IP block '%s'...",
 			publicIPBlock.ID,
 		)
 	}
@@ -136,69 +187,200 @@ func nukePublicIPBlocks(apiClient *compute.Client, networkDomainID string) error
 	return nil
 }
 
-func nukeServers(apiClient *compute.Client, networkDomainID string) error {
-	var servers []compute.Server
+func nukeServerAntiAffinityRules(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	antiAffinityRules, err := selectServerAntiAffinityRules(apiClient, networkDomainID, options)
+	if err != nil {
+		return err
+	}
+
+	for _, antiAffinityRule := range antiAffinityRules {
+		action := beginAction(logEvent{Stage: "antiaffinity", ResourceType: "antiAffinityRule", ResourceID: antiAffinityRule.ID, Action: "delete"},
+			"Deleting anti-affinity rule '%s' (%s)...",
+			antiAffinityRule.ID,
+			describeAntiAffinityServers(antiAffinityRule),
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteServerAntiAffinityRule(antiAffinityRule.ID, networkDomainID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("Anti-affinity rule '%s' has already been deleted.", antiAffinityRule.ID)
 
-	page := compute.DefaultPaging()
-	page.PageSize = 20
-	for {
-		result, err := apiClient.ListServersInNetworkDomain(networkDomainID, page)
+			continue
+		}
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
-		if result.IsEmpty() {
-			break
+
+		action.Complete("Deleted anti-affinity rule '%s' (%s).",
+			antiAffinityRule.ID,
+			describeAntiAffinityServers(antiAffinityRule),
+		)
+	}
+
+	return nil
+}
+
+// List the anti-affinity rules in the target network domain that cover servers selected by the server filter.
+func selectServerAntiAffinityRules(apiClient *compute.Client, networkDomainID string, options programOptions) ([]compute.ServerAntiAffinityRule, error) {
+	antiAffinityRules, err := listServerAntiAffinityRules(apiClient, networkDomainID)
+	if err != nil {
+		return nil, err
+	}
+	if len(antiAffinityRules) == 0 {
+		return nil, nil
+	}
+
+	servers, err := selectServers(apiClient, networkDomainID, options)
+	if err != nil {
+		return nil, err
+	}
+	selectedServerIDs := make(map[string]bool)
+	for _, server := range servers {
+		selectedServerIDs[server.ID] = true
+	}
+
+	var selected []compute.ServerAntiAffinityRule
+	for _, antiAffinityRule := range antiAffinityRules {
+		if coversSelectedServer(antiAffinityRule, selectedServerIDs) {
+			selected = append(selected, antiAffinityRule)
+		}
+	}
+
+	return selected, nil
+}
+
+func coversSelectedServer(antiAffinityRule compute.ServerAntiAffinityRule, selectedServerIDs map[string]bool) bool {
+	for _, server := range antiAffinityRule.Servers {
+		if selectedServerIDs[server.ID] {
+			return true
 		}
+	}
 
-		servers = append(servers, result.Items...)
+	return false
+}
 
-		page.Next()
+// Describe the servers covered by an anti-affinity rule (e.g. "'web1' ('id1') <-> 'web2' ('id2')").
+func describeAntiAffinityServers(antiAffinityRule compute.ServerAntiAffinityRule) string {
+	descriptions := make([]string, len(antiAffinityRule.Servers))
+	for index, server := range antiAffinityRule.Servers {
+		descriptions[index] = fmt.Sprintf("'%s' ('%s')", server.Name, server.ID)
 	}
 
+	return strings.Join(descriptions, " <-> ")
+}
+
+func nukeServers(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	servers, err := selectServers(apiClient, networkDomainID, options)
+	if err != nil {
+		return err
+	}
+
+	progress.SetStageTotal(len(servers))
+
 	asyncLock := &sync.Mutex{}
+	backupSlots := make(chan bool, options.BackupConcurrency)
 	deletionComplete := &sync.WaitGroup{}
 	deletionComplete.Add(len(servers))
 
-	failed := false
+	failures := &serverFailuresError{NetworkDomainID: networkDomainID}
 	for _, server := range servers {
 		go func(server compute.Server) {
 			defer deletionComplete.Done()
 
+			serverSpan := tracer.StartSpan(nil, "server", map[string]string{
+				"nifo.resource.id":   server.ID,
+				"nifo.resource.name": server.Name,
+			})
 			var err error
+			defer func() {
+				serverSpan.Finish(err)
+			}()
+
+			backupSubscription, err := removeServerBackup(apiClient, server, options.KeepLastBackup, serverSpan)
+			if err != nil {
+				logger.Println(err)
+				failures.Add(err)
+
+				return
+			}
+			if backupSubscription != nil {
+				backupSubscription.MonthlyCost = options.Rates.BackupPlanCost(backupSubscription.ServicePlan)
+				summary.AddBackupSubscription(*backupSubscription)
+				currentReport.AddBackupSubscription(*backupSubscription)
+			}
+
 			if server.Started {
-				err = hardStopServer(apiClient, server.ID)
+				err = hardStopServer(apiClient, server.ID, serverSpan, options)
+				if isMissingResource(err, options) {
+					return
+				}
+				if err != nil {
+					logger.Println(err)
+					failures.Add(err)
+
+					return
+				}
+			}
+
+			if options.BackupServers {
+				backupSlots <- true
+				var backup *serverBackup
+				backup, err = backupServer(apiClient, server, options.NetworkDomain, serverSpan)
+				<-backupSlots
 				if err != nil {
 					logger.Println(err)
-					failed = true
+					failures.Add(err)
 
 					return
 				}
+
+				summary.AddServerBackup(*backup)
+				currentReport.AddServerBackup(*backup)
 			}
 
+			lockSpan := tracer.StartSpan(serverSpan, "wait-for-async-lock", nil)
 			asyncLock.Lock()
-			logger.Printf("Destroying server '%s' ('%s')...",
+			lockSpan.Finish(nil)
+			action := beginChildAction(serverSpan, logEvent{Stage: "servers", ResourceType: "server", ResourceID: server.ID, ResourceName: server.Name, Action: "delete"},
+				"Destroying server '%s' ('%s')...",
 				server.Name,
 				server.ID,
 			)
 
+			deleteSpan := tracer.StartSpan(action.Span, "DeleteServer", nil)
+			call := action.BeginAPICall()
 			err = apiClient.DeleteServer(server.ID)
+			call.End(err)
+			deleteSpan.Finish(err)
 			asyncLock.Unlock()
+			if isMissingResource(err, options) {
+				action.AlreadyDeleted("Server '%s' ('%s') has already been deleted.", server.Name, server.ID)
+
+				return
+			}
 			if err != nil {
+				action.Fail(err)
 				logger.Println(err)
-				failed = true
+				failures.Add(err)
 
 				return
 			}
 
+			waitSpan := tracer.StartSpan(action.Span, "WaitForDelete", nil)
 			err = apiClient.WaitForDelete(compute.ResourceTypeServer, server.ID, 5*time.Minute)
+			waitSpan.Finish(err)
 			if err != nil {
+				action.Fail(err)
 				logger.Println(err)
-				failed = true
+				failures.Add(err)
 
 				return
 			}
 
-			logger.Printf("Destroyed server '%s' ('%s').",
+			action.Complete("Destroyed server '%s' ('%s').",
 				server.Name,
 				server.ID,
 			)
@@ -207,66 +389,264 @@ func nukeServers(apiClient *compute.Client, networkDomainID string) error {
 	}
 
 	deletionComplete.Wait()
-	if failed {
-		return fmt.Errorf("Destroy failed for one or more servers in network domain '%s'.", networkDomainID)
+	if len(failures.Errs) > 0 {
+		return failures
 	}
 
 	return nil
 }
 
-func hardStopServer(apiClient *compute.Client, serverID string) error {
-	logger.Printf("Stopping server '%s'...", serverID)
+// Determine whether an error indicates that a resource no longer exists (and should therefore be treated as already deleted).
+func isMissingResource(err error, options programOptions) bool {
+	if err == nil || !options.IgnoresMissing() {
+		return false
+	}
 
+	return compute.IsAPIErrorCode(err, compute.ResultCodeResourceNotFound)
+}
+
+// List the servers in the target network domain that are selected by the server filter.
+func selectServers(apiClient *compute.Client, networkDomainID string, options programOptions) ([]compute.Server, error) {
+	servers, err := listServers(apiClient, networkDomainID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := options.ServerFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.Apply(apiClient, servers)
+}
+
+func hardStopServer(apiClient *compute.Client, serverID string, parentSpan *span, options programOptions) error {
+	action := beginChildAction(parentSpan, logEvent{Stage: "servers", ResourceType: "server", ResourceID: serverID, Action: "power-off"},
+		"Stopping server '%s'...", serverID,
+	)
+
+	powerOffSpan := tracer.StartSpan(action.Span, "PowerOffServer", nil)
+	call := action.BeginAPICall()
 	err := apiClient.PowerOffServer(serverID)
+	call.End(err)
+	powerOffSpan.Finish(err)
+	if isMissingResource(err, options) {
+		action.AlreadyDeleted("Server '%s' has already been deleted.", serverID)
+
+		return err
+	}
 	if err != nil {
+		action.Fail(err)
+
 		return err
 	}
 
+	waitSpan := tracer.StartSpan(action.Span, "WaitForChange", nil)
 	_, err = apiClient.WaitForChange(compute.ResourceTypeServer, serverID, "Stop server", 5*time.Minute)
+	waitSpan.Finish(err)
 	if err != nil {
+		action.Fail(err)
+
 		return err
 	}
 
-	logger.Printf("Stopped server '%s'...", serverID)
+	action.Complete("Stopped server '%s'...", serverID)
 
 	return nil
 }
 
-func nukeVLANs(apiClient *compute.Client, networkDomainID string) error {
-	var vlans []compute.VLAN
+func nukeStaticRoutes(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	staticRoutes, err := listUserStaticRoutes(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, staticRoute := range staticRoutes {
+		action := beginAction(logEvent{Stage: "staticroutes", ResourceType: "staticRoute", ResourceID: staticRoute.ID, Action: "delete"},
+			"Deleting static route '%s' (%s/%d -> %s)...",
+			staticRoute.ID,
+			staticRoute.DestinationNetworkAddress,
+			staticRoute.DestinationPrefixSize,
+			staticRoute.NextHopAddress,
+		)
+
+		call := action.BeginAPICall()
+		err := apiClient.DeleteStaticRoute(staticRoute.ID)
+		call.End(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("Static route '%s' has already been deleted.", staticRoute.ID)
 
-	page := compute.DefaultPaging()
-	page.PageSize = 20
-	for {
-		result, err := apiClient.ListVLANs(networkDomainID, page)
+			continue
+		}
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
-		if result.IsEmpty() {
-			break
+
+		action.Complete("Deleted static route '%s' (%s/%d -> %s).",
+			staticRoute.ID,
+			staticRoute.DestinationNetworkAddress,
+			staticRoute.DestinationPrefixSize,
+			staticRoute.NextHopAddress,
+		)
+	}
+
+	return nil
+}
+
+// List the user-defined (i.e. non-system) static routes in the target network domain.
+func listUserStaticRoutes(apiClient *compute.Client, networkDomainID string) ([]compute.StaticRoute, error) {
+	staticRoutes, err := listStaticRoutes(apiClient, networkDomainID)
+	if err != nil {
+		return nil, err
+	}
+
+	var userStaticRoutes []compute.StaticRoute
+	for _, staticRoute := range staticRoutes {
+		if staticRoute.Type == "CLIENT" {
+			userStaticRoutes = append(userStaticRoutes, staticRoute)
 		}
+	}
 
-		vlans = append(vlans, result.VLANs...)
+	return userStaticRoutes, nil
+}
 
-		page.Next()
+func nukeReservedIPAddresses(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	vlans, err := listVLANs(apiClient, networkDomainID)
+	if err != nil {
+		return err
 	}
 
 	for _, vlan := range vlans {
-		logger.Printf("Deleting VLAN '%s'...",
+		reservedIPv4Addresses, err := listReservedPrivateIPv4Addresses(apiClient, vlan.ID)
+		if err != nil {
+			return err
+		}
+		for _, reservedAddress := range reservedIPv4Addresses {
+			action := beginAction(logEvent{Stage: "reservedips", ResourceType: "reservedIPv4Address", ResourceID: reservedAddress.IPAddress, Action: "unreserve"},
+				"Unreserving private IPv4 address '%s' in VLAN '%s'...",
+				reservedAddress.IPAddress,
+				vlan.ID,
+			)
+
+			call := action.BeginAPICall()
+			err := apiClient.UnreservePrivateIPv4Address(vlan.ID, reservedAddress.IPAddress)
+			call.End(err)
+			if isMissingResource(err, options) {
+				action.AlreadyDeleted("Private IPv4 address '%s' in VLAN '%s' is no longer reserved.", reservedAddress.IPAddress, vlan.ID)
+
+				continue
+			}
+			if err != nil {
+				action.Fail(err)
+
+				return err
+			}
+
+			action.Complete("Unreserved private IPv4 address '%s' in VLAN '%s'.",
+				reservedAddress.IPAddress,
+				vlan.ID,
+			)
+		}
+
+		reservedIPv6Addresses, err := listReservedIPv6Addresses(apiClient, vlan.ID)
+		if err != nil {
+			return err
+		}
+		for _, reservedAddress := range reservedIPv6Addresses {
+			action := beginAction(logEvent{Stage: "reservedips", ResourceType: "reservedIPv6Address", ResourceID: reservedAddress.IPAddress, Action: "unreserve"},
+				"Unreserving IPv6 address '%s' in VLAN '%s'...",
+				reservedAddress.IPAddress,
+				vlan.ID,
+			)
+
+			call := action.BeginAPICall()
+			err := apiClient.UnreserveIPv6Address(vlan.ID, reservedAddress.IPAddress)
+			call.End(err)
+			if isMissingResource(err, options) {
+				action.AlreadyDeleted("IPv6 address '%s' in VLAN '%s' is no longer reserved.", reservedAddress.IPAddress, vlan.ID)
+
+				continue
+			}
+			if err != nil {
+				action.Fail(err)
+
+				return err
+			}
+
+			action.Complete("Unreserved IPv6 address '%s' in VLAN '%s'.",
+				reservedAddress.IPAddress,
+				vlan.ID,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Count the reserved private IPv4 and IPv6 addresses in all VLANs in the target network domain.
+func countReservedIPAddresses(apiClient *compute.Client, networkDomainID string) (int, error) {
+	vlans, err := listVLANs(apiClient, networkDomainID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, vlan := range vlans {
+		reservedIPv4Addresses, err := listReservedPrivateIPv4Addresses(apiClient, vlan.ID)
+		if err != nil {
+			return 0, err
+		}
+		reservedIPv6Addresses, err := listReservedIPv6Addresses(apiClient, vlan.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		count += len(reservedIPv4Addresses) + len(reservedIPv6Addresses)
+	}
+
+	return count, nil
+}
+
+func nukeVLANs(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	vlans, err := listVLANs(apiClient, networkDomainID)
+	if err != nil {
+		return err
+	}
+
+	for _, vlan := range vlans {
+		action := beginAction(logEvent{Stage: "vlans", ResourceType: "vlan", ResourceID: vlan.ID, ResourceName: vlan.Name, Action: "delete"},
+			"Deleting VLAN '%s'...",
 			vlan.ID,
 		)
 
+		deleteSpan := tracer.StartSpan(action.Span, "DeleteVLAN", nil)
+		call := action.BeginAPICall()
 		err := apiClient.DeleteVLAN(vlan.ID)
+		call.End(err)
+		deleteSpan.Finish(err)
+		if isMissingResource(err, options) {
+			action.AlreadyDeleted("VLAN '%s' has already been deleted.", vlan.ID)
+
+			continue
+		}
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
 
+		waitSpan := tracer.StartSpan(action.Span, "WaitForDelete", nil)
 		err = apiClient.WaitForDelete(compute.ResourceTypeVLAN, vlan.ID, 5*time.Minute)
+		waitSpan.Finish(err)
 		if err != nil {
+			action.Fail(err)
+
 			return err
 		}
 
-		logger.Printf("Deleted VLAN '%s'...",
+		action.Complete("Deleted VLAN '%s'...",
 			vlan.ID,
 		)
 	}
@@ -274,15 +654,26 @@ func nukeVLANs(apiClient *compute.Client, networkDomainID string) error {
 	return nil
 }
 
-func nukeNetworkDomain(apiClient *compute.Client, networkDomainID string) error {
-	logger.Printf("Deleting network domain '%s'...", networkDomainID)
+func nukeNetworkDomain(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+	action := beginAction(logEvent{Stage: "networkdomain", ResourceType: "networkDomain", ResourceID: networkDomainID, Action: "delete"},
+		"Deleting network domain '%s'...", networkDomainID,
+	)
 
+	call := action.BeginAPICall()
 	err := apiClient.DeleteNetworkDomain(networkDomainID)
+	call.End(err)
+	if isMissingResource(err, options) {
+		action.AlreadyDeleted("Network domain '%s' has already been deleted.", networkDomainID)
+
+		return nil
+	}
 	if err != nil {
+		action.Fail(err)
+
 		return err
 	}
 
-	logger.Printf("Deleted network domain '%s'.", networkDomainID)
+	action.Complete("Deleted network domain '%s'.", networkDomainID)
 
 	return nil
 }
@@ -0,0 +1,81 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestCoversSelectedServer(t *testing.T) {
+	antiAffinityRule := compute.ServerAntiAffinityRule{
+		ID: "rule1",
+		Servers: []compute.ServerSummary{
+			{ID: "server1", Name: "web-1"},
+			{ID: "server2", Name: "web-2"},
+		},
+	}
+
+	testCases := []struct {
+		Name     string
+		Selected map[string]bool
+		Expected bool
+	}{
+		{
+			Name:     "no servers selected",
+			Selected: map[string]bool{},
+			Expected: false,
+		},
+		{
+			Name:     "first server selected",
+			Selected: map[string]bool{"server1": true},
+			Expected: true,
+		},
+		{
+			Name:     "second server selected",
+			Selected: map[string]bool{"server2": true},
+			Expected: true,
+		},
+		{
+			Name:     "other server selected",
+			Selected: map[string]bool{"server3": true},
+			Expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		if actual := coversSelectedServer(antiAffinityRule, testCase.Selected); actual != testCase.Expected {
+			t.Errorf("%s: expected %t, but got %t", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestDescribeAntiAffinityServers(t *testing.T) {
+	antiAffinityRule := compute.ServerAntiAffinityRule{
+		ID: "rule1",
+		Servers: []compute.ServerSummary{
+			{ID: "server1", Name: "web-1"},
+			{ID: "server2", Name: "web-2"},
+		},
+	}
+
+	expected := "'web-1' ('server1') <-> 'web-2' ('server2')"
+	if actual := describeAntiAffinityServers(antiAffinityRule); actual != expected {
+		t.Errorf("Expected '%s', but got '%s'", expected, actual)
+	}
+}
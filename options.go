@@ -19,23 +19,172 @@ package main
 import (
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
 	"github.com/jessevdk/go-flags"
 )
 
 type programOptions struct {
-	Region        string `short:"r" long:"region" description:"The CloudControl region to use (e.g. AU, NA, etc)."`
-	Datacenter    string `short:"d" long:"datacenter" description:"The name CloudControl data centre containing the resource(s) to export (e.g. AU10, NA9, etc)."`
-	NetworkDomain string `short:"n" long:"networkdomain" description:"The name of tje network domain to nuke."`
-	Force         bool   `short:"f" long:"force" description:"Destroy the network domain without prompting."`
-	Verbose       bool   `short:"v" long:"verbose" description:"Display detailed information about the program's activities."`
-	Version       bool   `long:"version" description:"Display program version info."`
-	ShowHelp      bool   `short:"?" long:"help" description:"Show program help."`
+	Region                   string   `short:"r" long:"region" description:"The CloudControl region to use (e.g. AU, NA, etc)."`
+	Datacenter               string   `short:"d" long:"datacenter" description:"The name CloudControl data centre containing the resource(s) to export (e.g. AU10, NA9, etc)."`
+	NetworkDomain            string   `short:"n" long:"networkdomain" description:"The name of tje network domain to nuke."`
+	Only                     string   `long:"only" description:"Only run the specified stages (comma-separated, e.g. servers,natrules)."`
+	Skip                     string   `long:"skip" description:"Do not run the specified stages (comma-separated, e.g. publicips)."`
+	ServerMatch              []string `long:"server-match" description:"Only destroy servers whose names match the specified glob pattern (can be specified multiple times)."`
+	ServerTag                []string `long:"server-tag" description:"Only destroy servers with the specified tag, as key=value (can be specified multiple times)."`
+	ServerVLAN               []string `long:"server-vlan" description:"Only destroy servers attached to the specified VLAN (can be specified multiple times)."`
+	ExcludeServerMatch       []string `long:"exclude-server-match" description:"Do not destroy servers whose names match the specified glob pattern (can be specified multiple times)."`
+	ExcludeServerTag         []string `long:"exclude-server-tag" description:"Do not destroy servers with the specified tag, as key=value (can be specified multiple times)."`
+	ExcludeServerVLAN        []string `long:"exclude-server-vlan" description:"Do not destroy servers attached to the specified VLAN (can be specified multiple times)."`
+	BackupServers            bool     `long:"backup-servers" description:"Clone each server to a customer image before destroying it."`
+	BackupConcurrency        int      `long:"backup-concurrency" default:"2" description:"The maximum number of servers to clone at the same time (when using --backup-servers)."`
+	KeepLastBackup           bool     `long:"keep-last-backup" description:"Wait for running Cloud Backup jobs to complete (so the last backup is kept) instead of cancelling them."`
+	InventoryDirectory       string   `long:"inventory-dir" default:"." description:"The directory where the pre-nuke inventory of the network domain will be written."`
+	InventoryFormat          string   `long:"inventory-format" default:"json" choice:"json" choice:"yaml" description:"The format of the pre-nuke inventory of the network domain."`
+	NoInventory              bool     `long:"no-inventory" description:"Do not write an inventory of the network domain before nuking it."`
+	NotifyURLs               []string `long:"notify-url" description:"A webhook URL to notify (with a JSON payload) when a nuke starts, after each stage, and when it finishes (can be specified multiple times)."`
+	AuditLog                 string   `long:"audit-log" description:"Append a tamper-evident record of each run that can change resources (and every change it makes) to this file."`
+	AuditSyslog              bool     `long:"audit-syslog" description:"Also write audit records to syslog (requires --audit-log; not supported on Windows)."`
+	TraceFile                string   `long:"trace-file" description:"Append a trace of each nuke (with a span for each stage and resource operation) to this file, in OTLP JSON format."`
+	TraceEndpoint            string   `long:"trace-endpoint" description:"Post a trace of each nuke to this OTLP (HTTP / JSON) collector endpoint (e.g. http://localhost:4318/v1/traces)."`
+	Report                   string   `long:"report" description:"Write a report of every resource touched (and the outcome) to this file (report.json or report.md)."`
+	RateCard                 string   `long:"rate-card" description:"Estimate the monthly cost savings of the nuke using the per-datacenter rates in this YAML file."`
+	IgnoreMissing            bool     `long:"ignore-missing" description:"Treat a network domain or resource that no longer exists as already deleted (the default when using --force)."`
+	FailOnMissing            bool     `long:"fail-on-missing" description:"Fail if the network domain or a resource no longer exists (even when using --force)."`
+	IgnoreExternalReferences bool     `long:"ignore-external-references" description:"Nuke the network domain even if resources in other network domains refer to its public IP addresses."`
+	Force                    bool     `short:"f" long:"force" description:"Destroy the network domain without prompting."`
+	Verbose                  bool     `short:"v" long:"verbose" description:"Display detailed information about the program's activities."`
+	LogFormat                string   `long:"log-format" choice:"text" choice:"json" default:"text" description:"The format for log output (json emits one structured event per line)."`
+	NoProgress               bool     `long:"no-progress" description:"Do not show a live progress display (even if the output is a terminal)."`
+	Version                  bool     `long:"version" description:"Display program version info."`
+	ShowHelp                 bool     `short:"?" long:"help" description:"Show program help."`
+
+	Restore    restoreOptions    `command:"restore" description:"Recreate the skeleton of a nuked network domain from its inventory."`
+	Strip      stripOptions      `command:"strip" description:"Remove additional network adapters and non-primary disks from servers in a network domain (without deleting the servers)."`
+	Extend     extendOptions     `command:"extend" description:"Extend the lifetime of a network domain that has an expiry (or time-to-live) tag."`
+	Audit      auditOptions      `command:"audit" description:"Work with the audit log."`
+	Janitor    janitorOptions    `command:"janitor" description:"Periodically nuke network domains whose expiry tags (nifo:expires or nifo:ttl) have passed."`
+	Reap       reapOptions       `command:"reap" description:"Find network domains that are empty or abandoned, and nuke the ones you select."`
+	ReclaimIPs reclaimIPsOptions `command:"reclaim-ips" description:"Find and remove unused public IP blocks in every network domain in a datacenter (or region)."`
+
+	// The name of the command being run (empty when nuking a network domain).
+	Command string `no-flag:"yes"`
+
+	// The rates (from --rate-card) for the target network domain's datacenter, once loaded (nil if there are none).
+	Rates *rateCard `no-flag:"yes"`
 }
 
 // Validate the programOptions.
 func (options programOptions) Validate() error {
+	if options.IgnoreMissing && options.FailOnMissing {
+		return fmt.Errorf("Cannot specify both --ignore-missing and --fail-on-missing.")
+	}
+
+	if options.Report != "" {
+		switch strings.ToLower(filepath.Ext(options.Report)) {
+		case ".json", ".md":
+		default:
+			return fmt.Errorf("Report file must end with .json or .md.")
+		}
+	}
+
+	if options.AuditSyslog && options.AuditLog == "" {
+		return fmt.Errorf("Cannot specify --audit-syslog without --audit-log.")
+	}
+
+	if options.NukesServers() && options.BackupConcurrency < 1 {
+		return fmt.Errorf("Backup concurrency must be at least 1.")
+	}
+
+	if options.Command == "restore" || options.Command == "reclaim-ips" {
+		if options.Region == "" {
+			return fmt.Errorf("Must specify the target region.")
+		}
+
+		return nil
+	}
+
+	if options.Command == "audit verify" {
+		return nil
+	}
+
+	if options.Command == "extend" {
+		if options.Region == "" {
+			return fmt.Errorf("Must specify the target region.")
+		}
+
+		if options.Datacenter == "" {
+			return fmt.Errorf("Must specify the target datacenter.")
+		}
+
+		if options.Extend.By <= 0 {
+			return fmt.Errorf("Must specify a positive amount of time to extend the network domain's lifetime by.")
+		}
+
+		return nil
+	}
+
+	if options.Command == "janitor" {
+		if options.Region == "" && len(options.Janitor.Targets) == 0 {
+			return fmt.Errorf("Must specify the target region (or at least one janitor target).")
+		}
+
+		if options.Janitor.Interval <= 0 {
+			return fmt.Errorf("Janitor interval must be greater than zero.")
+		}
+
+		for _, warnBefore := range options.Janitor.WarnBefore {
+			if warnBefore <= 0 {
+				return fmt.Errorf("Expiry warning times (--warn-before) must be greater than zero.")
+			}
+		}
+
+		_, err := options.JanitorTargets()
+
+		return err
+	}
+
+	if options.Command == "reap" {
+		if options.Region == "" {
+			return fmt.Errorf("Must specify the target region.")
+		}
+
+		if !options.Reap.Empty && options.Reap.DeployedBefore == 0 && len(options.Reap.NamePatterns) == 0 {
+			return fmt.Errorf("Must specify at least one of --empty, --deployed-before, or --name-pattern.")
+		}
+
+		if options.Reap.MinAge < 0 {
+			return fmt.Errorf("Minimum network domain age (--min-age) cannot be negative.")
+		}
+
+		if options.Force && options.Reap.Select == "" {
+			return fmt.Errorf("Must specify which network domains to reap (--select) when using --force.")
+		}
+
+		for _, pattern := range options.Reap.NamePatterns {
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("Invalid network domain name pattern '%s'.", pattern)
+			}
+		}
+
+		filter, err := options.ServerFilter()
+		if err != nil {
+			return err
+		}
+		err = filter.Validate()
+		if err != nil {
+			return err
+		}
+
+		_, err = options.Stages()
+
+		return err
+	}
+
 	if options.Region == "" {
 		return fmt.Errorf("Must specify the target region.")
 	}
@@ -48,9 +197,108 @@ func (options programOptions) Validate() error {
 		return fmt.Errorf("Must specify the target network domain.")
 	}
 
+	filter, err := options.ServerFilter()
+	if err != nil {
+		return err
+	}
+	err = filter.Validate()
+	if err != nil {
+		return err
+	}
+
+	if options.Command == "strip" {
+		return nil
+	}
+
+	_, err = options.Stages()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
+// NukesServers determines whether the command being run can destroy servers (i.e. it nukes network domains).
+func (options programOptions) NukesServers() bool {
+	switch options.Command {
+	case "", "janitor", "reap":
+		return true
+	default:
+		return false
+	}
+}
+
+// ChangesResources determines whether the command being run can change resources (and so should be audited).
+func (options programOptions) ChangesResources() bool {
+	switch options.Command {
+	case "audit verify":
+		return false
+	case "reclaim-ips":
+		return !options.ReclaimIPs.DryRun
+	default:
+		return true
+	}
+}
+
+// IgnoresMissing determines whether a network domain or resource that no longer exists should be treated as already deleted.
+//
+// This is the default for unattended runs (i.e. with --force, which the janitor also uses), so that re-runs and concurrent cleanups converge.
+func (options programOptions) IgnoresMissing() bool {
+	if options.FailOnMissing {
+		return false
+	}
+
+	return options.IgnoreMissing || options.Force
+}
+
+// Stages determines the stages to run, based on the --only and --skip options.
+//
+// If only some servers are to be destroyed, then only the stages that act on the selected servers (anti-affinity rules and
+// the servers themselves) run by default; stages that destroy resources shared by the whole network domain (e.g. NAT rules,
+// public IP blocks, and load-balancer objects) only run if they are explicitly named in --only. The network domain will not
+// be empty, so the VLAN and network domain stages (and the stages that only exist to clear the way for deleting VLANs)
+// cannot run at all.
+func (options programOptions) Stages() ([]nukeStage, error) {
+	only := splitList(options.Only)
+	skip := splitList(options.Skip)
+
+	filter, err := options.ServerFilter()
+	if err != nil {
+		return nil, err
+	}
+	if !filter.IsEmpty() {
+		for _, stage := range allStages {
+			if containsString(serverScopedStageNames, stage.Name) {
+				continue
+			}
+			if containsString(emptyDomainStageNames, stage.Name) && containsString(only, stage.Name) {
+				return nil, fmt.Errorf("Cannot run stage '%s' when only some servers are being destroyed.", stage.Name)
+			}
+			if !containsString(only, stage.Name) {
+				skip = append(skip, stage.Name)
+			}
+		}
+	}
+
+	return selectStages(only, skip)
+}
+
+// ServerFilter creates a filter that selects servers based on the --server-xxx and --exclude-server-xxx options.
+func (options programOptions) ServerFilter() (filter serverFilter, err error) {
+	filter.Names = options.ServerMatch
+	filter.VLANs = options.ServerVLAN
+	filter.ExcludeNames = options.ExcludeServerMatch
+	filter.ExcludeVLANs = options.ExcludeServerVLAN
+
+	filter.Tags, err = parseServerTagSelectors(options.ServerTag)
+	if err != nil {
+		return
+	}
+	filter.ExcludeTags, err = parseServerTagSelectors(options.ExcludeServerTag)
+
+	return
+}
+
 // Create a CloudControl client.
 func (options programOptions) CreateClient() (client *compute.Client, err error) {
 	if options.Region == "" {
@@ -61,14 +309,18 @@ func (options programOptions) CreateClient() (client *compute.Client, err error)
 
 	username := os.Getenv("MCP_USER")
 	if username == "" {
-		err = fmt.Errorf("The MCP_USER environment variable has not been set. Set it to your CloudControl username.")
+		err = &authenticationError{
+			Err: fmt.Errorf("The MCP_USER environment variable has not been set. Set it to your CloudControl username."),
+		}
 
 		return
 	}
 
 	password := os.Getenv("MCP_PASSWORD")
-	if username == "" {
-		err = fmt.Errorf("The MCP_PASSWORD environment variable has not been set. Set it to your CloudControl password.")
+	if password == "" {
+		err = &authenticationError{
+			Err: fmt.Errorf("The MCP_PASSWORD environment variable has not been set. Set it to your CloudControl password."),
+		}
 
 		return
 	}
@@ -82,7 +334,14 @@ func parseOptions() programOptions {
 	options := programOptions{}
 
 	parser := flags.NewParser(&options, flags.Default)
-	_, err := parser.ParseArgs(os.Args)
+	parser.SubcommandsOptional = true
+	_, err := parser.ParseArgs(os.Args[1:])
+	if err == nil && parser.Active != nil {
+		options.Command = parser.Active.Name
+		if parser.Active.Active != nil {
+			options.Command += " " + parser.Active.Active.Name // e.g. "audit verify"
+		}
+	}
 	if err == nil {
 		err = options.Validate()
 	}
@@ -96,7 +355,7 @@ func parseOptions() programOptions {
 
 		showHelp()
 
-		os.Exit(1)
+		os.Exit(exitCodeBadOptions)
 	}
 
 	return options
@@ -106,6 +365,7 @@ func showHelp() {
 	options := programOptions{}
 
 	parser := flags.NewParser(&options, flags.Default)
+	parser.SubcommandsOptional = true
 	fmt.Println()
 	parser.WriteHelp(os.Stdout)
 }
@@ -0,0 +1,151 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStagesWithServerFilter(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Options  programOptions
+		Expected string
+		Error    bool
+	}{
+		{
+			Name:     "no filter",
+			Options:  programOptions{},
+			Expected: stageNames(allStages),
+		},
+		{
+			Name:     "filter only runs server stages",
+			Options:  programOptions{ServerMatch: []string{"web-*"}},
+			Expected: "antiaffinity,servers",
+		},
+		{
+			Name:     "filter with domain-wide stage named in only",
+			Options:  programOptions{ServerMatch: []string{"web-*"}, Only: "natrules,servers"},
+			Expected: "natrules,servers",
+		},
+		{
+			Name:     "filter with skip",
+			Options:  programOptions{ServerVLAN: []string{"vlan1"}, Skip: "antiaffinity"},
+			Expected: "servers",
+		},
+		{
+			Name:    "filter with VLAN stage named in only",
+			Options: programOptions{ServerMatch: []string{"web-*"}, Only: "servers,vlans"},
+			Error:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		stages, err := testCase.Options.Stages()
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("%s: expected an error, but got stages '%s'", testCase.Name, stageNames(stages))
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+
+			continue
+		}
+		if actual := stageNames(stages); actual != testCase.Expected {
+			t.Errorf("%s: expected stages '%s', but got '%s'", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestValidateBackupConcurrency(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Options programOptions
+		Error   bool
+	}{
+		{
+			Name:    "nuke",
+			Options: programOptions{Region: "AU", Datacenter: "AU9", NetworkDomain: "my-domain", BackupConcurrency: 0},
+			Error:   true,
+		},
+		{
+			Name:    "nuke without --backup-servers",
+			Options: programOptions{Region: "AU", Datacenter: "AU9", NetworkDomain: "my-domain", BackupConcurrency: -1},
+			Error:   true,
+		},
+		{
+			Name:    "nuke with valid concurrency",
+			Options: programOptions{Region: "AU", Datacenter: "AU9", NetworkDomain: "my-domain", BackupServers: true, BackupConcurrency: 2},
+		},
+		{
+			Name:    "janitor",
+			Options: programOptions{Command: "janitor", Region: "AU", BackupServers: true, Janitor: janitorOptions{Interval: time.Hour}},
+			Error:   true,
+		},
+		{
+			Name:    "reap",
+			Options: programOptions{Command: "reap", Region: "AU", BackupServers: true, Reap: reapOptions{Empty: true}},
+			Error:   true,
+		},
+		{
+			Name:    "reap with valid concurrency",
+			Options: programOptions{Command: "reap", Region: "AU", BackupServers: true, BackupConcurrency: 2, Reap: reapOptions{Empty: true}},
+		},
+		{
+			Name:    "restore does not nuke",
+			Options: programOptions{Command: "restore", Region: "AU"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.Options.Validate()
+		if testCase.Error && err == nil {
+			t.Errorf("%s: expected an error for backup concurrency %d", testCase.Name, testCase.Options.BackupConcurrency)
+		}
+		if !testCase.Error && err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+		}
+	}
+}
+
+func TestIgnoresMissing(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Options  programOptions
+		Expected bool
+	}{
+		{"default", programOptions{}, false},
+		{"--ignore-missing", programOptions{IgnoreMissing: true}, true},
+		{"--force", programOptions{Force: true}, true},
+		{"--force --fail-on-missing", programOptions{Force: true, FailOnMissing: true}, false},
+	}
+
+	for _, testCase := range testCases {
+		if actual := testCase.Options.IgnoresMissing(); actual != testCase.Expected {
+			t.Errorf("%s: expected %t, but got %t", testCase.Name, testCase.Expected, actual)
+		}
+	}
+
+	err := programOptions{Region: "AU", Datacenter: "AU9", NetworkDomain: "my-domain", BackupConcurrency: 2, IgnoreMissing: true, FailOnMissing: true}.Validate()
+	if err == nil {
+		t.Error("Expected an error when both --ignore-missing and --fail-on-missing are specified")
+	}
+}
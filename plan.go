@@ -0,0 +1,68 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// Display the plan for a nuke (what each selected stage will destroy).
+func showPlan(apiClient *compute.Client, networkDomain *compute.NetworkDomain, stages []nukeStage, options programOptions) error {
+	fmt.Fprintf(console, "Plan for network domain '%s' (Id = '%s'):\n", networkDomain.Name, networkDomain.ID)
+
+	width := stageNameWidth()
+
+	for _, stage := range stages {
+		if stage.Name == "networkdomain" {
+			fmt.Fprintf(console, "  %-*s %s\n", width, stage.Name, stage.Description)
+
+			continue
+		}
+
+		count, err := stage.Count(apiClient, networkDomain.ID, options)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(console, "  %-*s %d %s\n", width, stage.Name, count, stage.Description)
+
+		if stage.Details != nil && count > 0 {
+			details, err := stage.Details(apiClient, networkDomain.ID, options)
+			if err != nil {
+				return err
+			}
+			for _, detail := range details {
+				fmt.Fprintf(console, "  %-*s   %s\n", width, "", detail)
+			}
+		}
+	}
+
+	return nil
+}
+
+// The width of the stage name column in the plan (the length of the longest stage name).
+func stageNameWidth() int {
+	width := 0
+	for _, stage := range allStages {
+		if len(stage.Name) > width {
+			width = len(stage.Name)
+		}
+	}
+
+	return width
+}
@@ -0,0 +1,33 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestStageNameWidth(t *testing.T) {
+	width := stageNameWidth()
+	if width != len("ssloffloadprofiles") {
+		t.Errorf("Expected the stage name column to be %d characters wide, but got %d", len("ssloffloadprofiles"), width)
+	}
+	for _, stage := range allStages {
+		if len(stage.Name) > width {
+			t.Errorf("Stage name '%s' is wider than the stage name column (%d)", stage.Name, width)
+		}
+	}
+}
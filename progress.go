@@ -0,0 +1,304 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The live progress display (only enabled when STDOUT is a terminal).
+var progress = &progressDisplay{}
+
+// The maximum number of in-flight actions listed by the progress display.
+const maxProgressActions = 10
+
+// How often the progress display is redrawn.
+const progressRefreshInterval = 500 * time.Millisecond
+
+// progressDisplay is a live, multi-line view of a nuke's progress that is redrawn below the log output.
+//
+// When enabled, it is also the output for the program's loggers (so that log messages can be written above the view without garbling it).
+type progressDisplay struct {
+	lock    sync.Mutex
+	output  io.Writer
+	enabled bool
+	active  bool
+	stopped chan bool
+
+	started      time.Time
+	stageName    string
+	stageNumber  int
+	stageCount   int
+	stageStarted time.Time
+	stageTotal   int
+	completed    map[string]int
+	failed       map[string]int
+	inFlight     []*actionLog
+
+	drawnLines int
+}
+
+var _ io.Writer = &progressDisplay{}
+
+// Determine whether the specified file is a terminal.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Enable the live progress display (and route the program's loggers through it).
+func enableProgressDisplay(verbose bool) {
+	progress.output = os.Stdout
+	progress.enabled = true
+
+	logger.SetOutput(progress)
+	if verbose {
+		log.SetOutput(progress)
+	}
+}
+
+// Write writes a log message above the progress view.
+func (display *progressDisplay) Write(message []byte) (int, error) {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	display.clear()
+	written, err := display.output.Write(message)
+	display.draw()
+
+	return written, err
+}
+
+// Start showing the progress of a nuke.
+func (display *progressDisplay) Start(stageCount int) {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	if !display.enabled {
+		return
+	}
+
+	display.active = true
+	display.started = time.Now()
+	display.stageCount = stageCount
+	display.stageNumber = 0
+	display.stopped = make(chan bool)
+
+	go func(stopped chan bool) {
+		ticker := time.NewTicker(progressRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				display.lock.Lock()
+				display.clear()
+				display.draw()
+				display.lock.Unlock()
+			case <-stopped:
+				return
+			}
+		}
+	}(display.stopped)
+}
+
+// Stop showing the progress of a nuke (the view is removed, leaving only log output).
+func (display *progressDisplay) Stop() {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	if !display.active {
+		return
+	}
+
+	close(display.stopped)
+	display.clear()
+	display.active = false
+}
+
+// StartStage records the start of a stage.
+func (display *progressDisplay) StartStage(stageName string) {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	display.stageName = stageName
+	display.stageNumber++
+	display.stageStarted = time.Now()
+	display.stageTotal = 0
+	display.completed = make(map[string]int)
+	display.failed = make(map[string]int)
+	display.inFlight = nil
+}
+
+// SetStageTotal records the total number of resources that the current stage will process.
+func (display *progressDisplay) SetStageTotal(total int) {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	display.stageTotal = total
+}
+
+// ActionStarted records the start of an action performed on a resource.
+func (display *progressDisplay) ActionStarted(action *actionLog) {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	if !display.active {
+		return
+	}
+
+	display.inFlight = append(display.inFlight, action)
+}
+
+// ActionEnded records the completion (or failure) of an action performed on a resource.
+func (display *progressDisplay) ActionEnded(action *actionLog, err error) {
+	display.lock.Lock()
+	defer display.lock.Unlock()
+
+	if !display.active {
+		return
+	}
+
+	for index, inFlight := range display.inFlight {
+		if inFlight == action {
+			display.inFlight = append(display.inFlight[:index], display.inFlight[index+1:]...)
+
+			break
+		}
+	}
+
+	if err != nil {
+		display.failed[action.Event.Action]++
+	} else {
+		display.completed[action.Event.Action]++
+	}
+}
+
+// Remove the progress view from the terminal (the caller must hold the lock).
+func (display *progressDisplay) clear() {
+	if display.drawnLines == 0 {
+		return
+	}
+
+	// Move to the start of the first line of the view, and erase everything from there down.
+	fmt.Fprintf(display.output, "\x1b[%dA\r\x1b[J", display.drawnLines)
+	display.drawnLines = 0
+}
+
+// Draw the progress view (the caller must hold the lock).
+func (display *progressDisplay) draw() {
+	if !display.active || display.stageName == "" {
+		return
+	}
+
+	now := time.Now()
+	lines := []string{
+		fmt.Sprintf("Stage %d/%d: %s (%s; %s in total)",
+			display.stageNumber,
+			display.stageCount,
+			display.stageName,
+			formatElapsed(now.Sub(display.stageStarted)),
+			formatElapsed(now.Sub(display.started)),
+		),
+	}
+
+	inFlightByAction := make(map[string]int)
+	for _, action := range display.inFlight {
+		inFlightByAction[action.Event.Action]++
+	}
+
+	var actionNames []string
+	for actionName := range display.completed {
+		actionNames = append(actionNames, actionName)
+	}
+	for actionName := range display.failed {
+		if display.completed[actionName] == 0 {
+			actionNames = append(actionNames, actionName)
+		}
+	}
+	for actionName := range inFlightByAction {
+		if display.completed[actionName] == 0 && display.failed[actionName] == 0 {
+			actionNames = append(actionNames, actionName)
+		}
+	}
+	sort.Strings(actionNames)
+
+	for _, actionName := range actionNames {
+		line := fmt.Sprintf("  %s: %d in progress, %d done", actionName, inFlightByAction[actionName], display.completed[actionName])
+		if display.stageTotal > 0 {
+			line += fmt.Sprintf(" (of %d)", display.stageTotal)
+		}
+		if display.failed[actionName] > 0 {
+			line += fmt.Sprintf(", %d failed", display.failed[actionName])
+		}
+		lines = append(lines, line)
+	}
+
+	for index, action := range display.inFlight {
+		if index == maxProgressActions {
+			lines = append(lines, fmt.Sprintf("    ... and %d more", len(display.inFlight)-maxProgressActions))
+
+			break
+		}
+
+		resource := resourceReport{
+			ResourceType: action.Event.ResourceType,
+			ResourceID:   action.Event.ResourceID,
+			ResourceName: action.Event.ResourceName,
+		}
+		lines = append(lines, fmt.Sprintf("    %s: %s (%s)",
+			describeReportedResource(resource),
+			action.Event.Action,
+			formatElapsed(now.Sub(action.Started)),
+		))
+	}
+
+	width := terminalWidth()
+	for _, line := range lines {
+		if len(line) >= width {
+			line = line[:width-4] + "..."
+		}
+		fmt.Fprintln(display.output, line)
+	}
+	display.drawnLines = len(lines)
+}
+
+// Estimate the width of the terminal (long lines would wrap, and then not be completely removed when the view is redrawn).
+func terminalWidth() int {
+	width, err := strconv.Atoi(os.Getenv("COLUMNS"))
+	if err != nil || width < 20 {
+		return 80
+	}
+
+	return width
+}
+
+// Format an elapsed time (to the nearest second).
+func formatElapsed(elapsed time.Duration) string {
+	return (elapsed / time.Second * time.Second).String()
+}
@@ -0,0 +1,91 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressDisplayDraw(t *testing.T) {
+	output := &bytes.Buffer{}
+	display := &progressDisplay{
+		output:     output,
+		active:     true,
+		started:    time.Now(),
+		stageCount: 3,
+	}
+	display.StartStage("servers")
+	display.SetStageTotal(3)
+
+	deleteServer := func(serverID string) *actionLog {
+		return &actionLog{
+			Event:   logEvent{Stage: "servers", ResourceType: "server", ResourceID: serverID, Action: "delete"},
+			Started: time.Now(),
+		}
+	}
+	server1 := deleteServer("server1")
+	server2 := deleteServer("server2")
+	server3 := deleteServer("server3")
+	display.ActionStarted(server1)
+	display.ActionStarted(server2)
+	display.ActionStarted(server3)
+	display.ActionEnded(server1, nil)
+	display.ActionEnded(server3, errors.New("boom"))
+
+	display.draw()
+	lines := strings.Split(strings.TrimRight(output.String(), "\n"), "\n")
+	if len(lines) != 3 || display.drawnLines != 3 {
+		t.Fatalf("Expected 3 lines, but got %d (%d drawn):\n%s", len(lines), display.drawnLines, output)
+	}
+	if !strings.HasPrefix(lines[0], "Stage 1/3: servers (") {
+		t.Errorf("Unexpected stage line: %s", lines[0])
+	}
+	if lines[1] != "  delete: 1 in progress, 1 done (of 3), 1 failed" {
+		t.Errorf("Unexpected action line: %s", lines[1])
+	}
+	if !strings.Contains(lines[2], "server2") || strings.Contains(output.String(), "server1") {
+		t.Errorf("Expected only server2 to be listed as in progress, but got:\n%s", output)
+	}
+
+	output.Reset()
+	display.clear()
+	if output.String() != "\x1b[3A\r\x1b[J" || display.drawnLines != 0 {
+		t.Errorf("Expected the view to be cleared, but got %q", output.String())
+	}
+}
+
+func TestProgressDisplayDisabled(t *testing.T) {
+	display := &progressDisplay{}
+	display.Start(3)
+	display.StartStage("servers")
+	display.ActionStarted(&actionLog{Event: logEvent{Action: "delete"}})
+	display.Stop()
+
+	if display.active || len(display.inFlight) != 0 {
+		t.Errorf("Expected a disabled progress display to ignore actions, but got %+v", display)
+	}
+}
+
+func TestFormatElapsed(t *testing.T) {
+	if elapsed := formatElapsed(90*time.Second + 400*time.Millisecond); elapsed != "1m30s" {
+		t.Errorf("Expected 1m30s, but got %s", elapsed)
+	}
+}
@@ -0,0 +1,283 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// reapOptions represents the options for the "reap" command.
+type reapOptions struct {
+	Empty          bool          `long:"empty" description:"Reap network domains that contain no servers."`
+	DeployedBefore time.Duration `long:"deployed-before" description:"Reap network domains whose servers are all stopped and were all deployed longer ago than this (e.g. 720h)."`
+	NamePatterns   []string      `long:"name-pattern" description:"Reap network domains whose names match the specified glob pattern (can be specified multiple times)."`
+	MinAge         time.Duration `long:"min-age" default:"24h" description:"Never reap network domains that were created more recently than this."`
+	Select         string        `long:"select" description:"The network domains to reap without prompting, as a comma-separated list of numbers, names, or Ids (or 'all'); required when using --force."`
+}
+
+// A reapCandidate is a network domain that appears to be abandoned.
+type reapCandidate struct {
+	NetworkDomain  compute.NetworkDomain
+	Age            time.Duration
+	ServerCount    int
+	StartedCount   int
+	VLANCount      int
+	PublicIPBlocks int
+	Reasons        []string
+}
+
+// Find network domains that appear to be empty or abandoned, and nuke the ones the user selects.
+func reap(apiClient *compute.Client, options programOptions) error {
+	networkDomains, err := listNetworkDomainsInDatacenterOrRegion(apiClient, options.Datacenter)
+	if err != nil {
+		return err
+	}
+
+	var candidates []reapCandidate
+	for _, networkDomain := range networkDomains {
+		candidate, err := evaluateReapCandidate(apiClient, networkDomain, options.Reap)
+		if err != nil {
+			return err
+		}
+		if candidate != nil {
+			candidates = append(candidates, *candidate)
+		}
+	}
+
+	if len(candidates) == 0 {
+		fmt.Fprintf(console, "No network domains to reap (checked %d).\n", len(networkDomains))
+
+		return nil
+	}
+
+	fmt.Fprintf(console, "Found %d network domain(s) to reap (checked %d):\n", len(candidates), len(networkDomains))
+	for index, candidate := range candidates {
+		fmt.Fprintf(console, "  [%d] '%s' ('%s') in datacenter '%s': age %s, %d server(s) (%d running), %d VLAN(s), %d public IP block(s) - %s\n",
+			index+1,
+			candidate.NetworkDomain.Name,
+			candidate.NetworkDomain.ID,
+			candidate.NetworkDomain.DatacenterID,
+			formatAge(candidate.Age),
+			candidate.ServerCount,
+			candidate.StartedCount,
+			candidate.VLANCount,
+			candidate.PublicIPBlocks,
+			strings.Join(candidate.Reasons, ", "),
+		)
+	}
+
+	var selected []reapCandidate
+	if options.Reap.Select != "" {
+		selected, err = parseReapSelection(candidates, options.Reap.Select)
+	} else {
+		selected, err = selectReapCandidates(candidates)
+	}
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return errNotConfirmed
+	}
+
+	failed := 0
+	for _, candidate := range selected {
+		networkDomain := candidate.NetworkDomain
+
+		domainOptions := options
+		domainOptions.NetworkDomain = networkDomain.Name
+		domainOptions.Datacenter = networkDomain.DatacenterID
+
+		err = runNuke(apiClient, &networkDomain, domainOptions)
+		if err == errNotConfirmed {
+			logger.Printf("Skipping network domain '%s' ('%s').", networkDomain.Name, networkDomain.ID)
+
+			continue
+		}
+		if err != nil {
+			logger.Printf("Failed to reap network domain '%s' ('%s'): %s", networkDomain.Name, networkDomain.ID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return &partialFailureError{
+			Err: fmt.Errorf("Failed to reap %d of %d network domain(s).", failed, len(selected)),
+		}
+	}
+
+	return nil
+}
+
+// Determine whether a network domain should be reaped (returns nil if it should not).
+func evaluateReapCandidate(apiClient *compute.Client, networkDomain compute.NetworkDomain, options reapOptions) (*reapCandidate, error) {
+	servers, err := listServers(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	candidate := matchReapCandidate(networkDomain, servers, options)
+	if candidate == nil {
+		return nil, nil
+	}
+
+	vlans, err := listVLANs(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	candidate.VLANCount = len(vlans)
+
+	publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	candidate.PublicIPBlocks = len(publicIPBlocks)
+
+	return candidate, nil
+}
+
+// Match a network domain (and its servers) against the reap criteria (returns nil if it should not be reaped).
+func matchReapCandidate(networkDomain compute.NetworkDomain, servers []compute.Server, options reapOptions) *reapCandidate {
+	candidate := &reapCandidate{
+		NetworkDomain: networkDomain,
+		Age:           ageOf(networkDomain.CreateTime),
+		ServerCount:   len(servers),
+	}
+
+	// A network domain whose creation time is unknown has an age of 0, so the minimum age excludes it.
+	if options.MinAge > 0 && candidate.Age < options.MinAge {
+		return nil
+	}
+
+	var newestServerAge time.Duration = -1
+	for _, server := range servers {
+		if server.Started {
+			candidate.StartedCount++
+		}
+
+		serverAge := ageOf(server.CreateTime)
+		if newestServerAge < 0 || serverAge < newestServerAge {
+			newestServerAge = serverAge
+		}
+	}
+
+	if options.Empty && len(servers) == 0 {
+		candidate.Reasons = append(candidate.Reasons, "no servers")
+	}
+
+	// CloudControl does not report when a server was stopped, so this only checks when the newest server was deployed.
+	if options.DeployedBefore > 0 && len(servers) > 0 && candidate.StartedCount == 0 && newestServerAge > options.DeployedBefore {
+		candidate.Reasons = append(candidate.Reasons,
+			fmt.Sprintf("all servers stopped, newest deployed %s ago", formatAge(newestServerAge)),
+		)
+	}
+
+	for _, pattern := range options.NamePatterns {
+		matched, _ := path.Match(pattern, networkDomain.Name)
+		if matched {
+			candidate.Reasons = append(candidate.Reasons,
+				fmt.Sprintf("name matches '%s'", pattern),
+			)
+
+			break
+		}
+	}
+
+	if len(candidate.Reasons) == 0 {
+		return nil
+	}
+
+	return candidate
+}
+
+// Ask the user which network domains to reap.
+func selectReapCandidates(candidates []reapCandidate) ([]reapCandidate, error) {
+	fmt.Fprintf(console, "Enter the network domains to reap (comma-separated numbers, names, or Ids), or 'all' (leave blank to cancel): ")
+	input, _, err := stdin.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseReapSelection(candidates, string(input))
+}
+
+// Parse a selection of network domains to reap (a comma-separated list of numbers, names, or Ids, or "all").
+func parseReapSelection(candidates []reapCandidate, selection string) ([]reapCandidate, error) {
+	selection = strings.TrimSpace(selection)
+	if selection == "all" {
+		return candidates, nil
+	}
+
+	var selected []reapCandidate
+	seen := make(map[int]bool)
+	for _, item := range splitList(selection) {
+		index := findReapCandidate(candidates, item)
+		if index < 0 {
+			return nil, fmt.Errorf("Invalid selection '%s' (must be a number between 1 and %d, or the name or Id of a listed network domain).", item, len(candidates))
+		}
+		if seen[index] {
+			continue
+		}
+		seen[index] = true
+
+		selected = append(selected, candidates[index])
+	}
+
+	return selected, nil
+}
+
+// Find the index of the reap candidate identified by the specified number, name, or Id (returns -1 if there is no such candidate).
+func findReapCandidate(candidates []reapCandidate, item string) int {
+	number, err := strconv.Atoi(item)
+	if err == nil {
+		if number < 1 || number > len(candidates) {
+			return -1
+		}
+
+		return number - 1
+	}
+
+	for index, candidate := range candidates {
+		if candidate.NetworkDomain.Name == item || candidate.NetworkDomain.ID == item {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Determine how long ago the specified (RFC3339) time was (returns 0 if the time cannot be parsed).
+func ageOf(timestamp string) time.Duration {
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return 0
+	}
+
+	return time.Since(parsed)
+}
+
+// Format an age in days and hours (e.g. "12d3h").
+func formatAge(age time.Duration) string {
+	days := int(age.Hours()) / 24
+	hours := int(age.Hours()) % 24
+
+	return fmt.Sprintf("%dd%dh", days, hours)
+}
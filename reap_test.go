@@ -0,0 +1,238 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestMatchReapCandidate(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		NetworkDomain compute.NetworkDomain
+		Servers       []compute.Server
+		Options       reapOptions
+		Reasons       string
+	}{
+		{
+			Name:          "empty",
+			NetworkDomain: testReapDomain("my-domain", 48*time.Hour),
+			Options:       reapOptions{Empty: true, MinAge: 24 * time.Hour},
+			Reasons:       "no servers",
+		},
+		{
+			Name:          "empty but too young",
+			NetworkDomain: testReapDomain("my-domain", time.Hour),
+			Options:       reapOptions{Empty: true, MinAge: 24 * time.Hour},
+		},
+		{
+			Name:          "empty with unknown creation time",
+			NetworkDomain: compute.NetworkDomain{Name: "my-domain"},
+			Options:       reapOptions{Empty: true, MinAge: 24 * time.Hour},
+		},
+		{
+			Name:          "empty without minimum age",
+			NetworkDomain: testReapDomain("my-domain", time.Hour),
+			Options:       reapOptions{Empty: true},
+			Reasons:       "no servers",
+		},
+		{
+			Name:          "not empty",
+			NetworkDomain: testReapDomain("my-domain", 48*time.Hour),
+			Servers:       []compute.Server{testReapServer(false, 48*time.Hour)},
+			Options:       reapOptions{Empty: true},
+		},
+		{
+			Name:          "all stopped and deployed before",
+			NetworkDomain: testReapDomain("my-domain", 800*time.Hour),
+			Servers:       []compute.Server{testReapServer(false, 790*time.Hour), testReapServer(false, 750*time.Hour)},
+			Options:       reapOptions{DeployedBefore: 720 * time.Hour},
+			Reasons:       "all servers stopped, newest deployed 31d6h ago",
+		},
+		{
+			Name:          "newest server deployed too recently",
+			NetworkDomain: testReapDomain("my-domain", 800*time.Hour),
+			Servers:       []compute.Server{testReapServer(false, 790*time.Hour), testReapServer(false, 10*time.Hour)},
+			Options:       reapOptions{DeployedBefore: 720 * time.Hour},
+		},
+		{
+			Name:          "one server running",
+			NetworkDomain: testReapDomain("my-domain", 800*time.Hour),
+			Servers:       []compute.Server{testReapServer(false, 790*time.Hour), testReapServer(true, 790*time.Hour)},
+			Options:       reapOptions{DeployedBefore: 720 * time.Hour},
+		},
+		{
+			Name:          "name matches first pattern only",
+			NetworkDomain: testReapDomain("tmp-build", 48*time.Hour),
+			Servers:       []compute.Server{testReapServer(true, 48*time.Hour)},
+			Options:       reapOptions{NamePatterns: []string{"prod-*", "tmp-*", "*-build"}},
+			Reasons:       "name matches 'tmp-*'",
+		},
+		{
+			Name:          "name does not match",
+			NetworkDomain: testReapDomain("prod-web", 48*time.Hour),
+			Options:       reapOptions{NamePatterns: []string{"tmp-*"}},
+		},
+		{
+			Name:          "several reasons",
+			NetworkDomain: testReapDomain("tmp-build", 48*time.Hour),
+			Options:       reapOptions{Empty: true, NamePatterns: []string{"tmp-*"}, MinAge: 24 * time.Hour},
+			Reasons:       "no servers,name matches 'tmp-*'",
+		},
+	}
+
+	for _, testCase := range testCases {
+		candidate := matchReapCandidate(testCase.NetworkDomain, testCase.Servers, testCase.Options)
+		if testCase.Reasons == "" {
+			if candidate != nil {
+				t.Errorf("%s: expected no candidate, but got one (%s)", testCase.Name, strings.Join(candidate.Reasons, ","))
+			}
+
+			continue
+		}
+		if candidate == nil {
+			t.Errorf("%s: expected a candidate (%s), but got none", testCase.Name, testCase.Reasons)
+
+			continue
+		}
+		if actual := strings.Join(candidate.Reasons, ","); actual != testCase.Reasons {
+			t.Errorf("%s: expected reasons '%s', but got '%s'", testCase.Name, testCase.Reasons, actual)
+		}
+		if candidate.ServerCount != len(testCase.Servers) {
+			t.Errorf("%s: expected %d server(s), but got %d", testCase.Name, len(testCase.Servers), candidate.ServerCount)
+		}
+	}
+}
+
+func TestParseReapSelection(t *testing.T) {
+	candidates := []reapCandidate{
+		{NetworkDomain: compute.NetworkDomain{ID: "id-1", Name: "domain-1"}},
+		{NetworkDomain: compute.NetworkDomain{ID: "id-2", Name: "domain-2"}},
+		{NetworkDomain: compute.NetworkDomain{ID: "id-3", Name: "domain-3"}},
+	}
+
+	testCases := []struct {
+		Name      string
+		Selection string
+		Expected  string
+		Error     bool
+	}{
+		{Name: "all", Selection: " all ", Expected: "id-1,id-2,id-3"},
+		{Name: "blank", Selection: "", Expected: ""},
+		{Name: "numbers", Selection: "3, 1", Expected: "id-3,id-1"},
+		{Name: "names and Ids", Selection: "domain-2,id-3", Expected: "id-2,id-3"},
+		{Name: "duplicates", Selection: "1,domain-1,id-1", Expected: "id-1"},
+		{Name: "number out of range", Selection: "4", Error: true},
+		{Name: "zero", Selection: "0", Error: true},
+		{Name: "unlisted network domain", Selection: "domain-4", Error: true},
+	}
+
+	for _, testCase := range testCases {
+		selected, err := parseReapSelection(candidates, testCase.Selection)
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("%s: expected an error", testCase.Name)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+
+			continue
+		}
+
+		var selectedIDs []string
+		for _, candidate := range selected {
+			selectedIDs = append(selectedIDs, candidate.NetworkDomain.ID)
+		}
+		if actual := strings.Join(selectedIDs, ","); actual != testCase.Expected {
+			t.Errorf("%s: expected '%s', but got '%s'", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestValidateReap(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Options programOptions
+		Error   bool
+	}{
+		{
+			Name:    "valid",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2, Reap: reapOptions{Empty: true}},
+		},
+		{
+			Name:    "no criteria",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2},
+			Error:   true,
+		},
+		{
+			Name:    "negative minimum age",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2, Reap: reapOptions{Empty: true, MinAge: -time.Hour}},
+			Error:   true,
+		},
+		{
+			Name:    "force without selection",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2, Force: true, Reap: reapOptions{Empty: true}},
+			Error:   true,
+		},
+		{
+			Name:    "force with selection",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2, Force: true, Reap: reapOptions{Empty: true, Select: "all"}},
+		},
+		{
+			Name:    "unknown stage",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2, Only: "servers,widgets", Reap: reapOptions{Empty: true}},
+			Error:   true,
+		},
+		{
+			Name:    "invalid server filter",
+			Options: programOptions{Command: "reap", Region: "AU", BackupConcurrency: 2, ServerMatch: []string{"["}, Reap: reapOptions{Empty: true}},
+			Error:   true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.Options.Validate()
+		if testCase.Error && err == nil {
+			t.Errorf("%s: expected an error", testCase.Name)
+		}
+		if !testCase.Error && err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+		}
+	}
+}
+
+func testReapDomain(name string, age time.Duration) compute.NetworkDomain {
+	return compute.NetworkDomain{
+		ID:         name + "-id",
+		Name:       name,
+		CreateTime: time.Now().Add(-age).Format(time.RFC3339),
+	}
+}
+
+func testReapServer(started bool, age time.Duration) compute.Server {
+	return compute.Server{
+		Started:    started,
+		CreateTime: time.Now().Add(-age).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,227 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// reclaimIPsOptions represents the options for the "reclaim-ips" command.
+type reclaimIPsOptions struct {
+	DryRun bool `long:"dry-run" description:"Only report unused public IP blocks (do not remove them)."`
+}
+
+// The public IP blocks in a network domain, and which of them are unused.
+type networkDomainPublicIPBlocks struct {
+	NetworkDomain  compute.NetworkDomain
+	PublicIPBlocks []compute.PublicIPBlock
+	UnusedBlocks   []compute.PublicIPBlock
+}
+
+// Find (and remove) public IP blocks, in every network domain in a datacenter or region, whose addresses are not used by any
+// NAT rule, virtual listener, or IP address list.
+func reclaimIPs(apiClient *compute.Client, options programOptions) error {
+	scope := fmt.Sprintf("region '%s'", options.Region)
+	if options.Datacenter != "" {
+		scope = fmt.Sprintf("datacenter '%s'", options.Datacenter)
+	}
+
+	logger.Printf("Scanning %s for unused public IP blocks...", scope)
+
+	networkDomains, err := listNetworkDomainsInDatacenterOrRegion(apiClient, options.Datacenter)
+	if err != nil {
+		return err
+	}
+
+	// IP address lists can be referenced from anywhere, so gather all of them before deciding whether a block is in use.
+	var addressListRanges []ipv4Range
+	for _, networkDomain := range networkDomains {
+		ipAddressLists, err := listIPAddressLists(apiClient, networkDomain.ID)
+		if err != nil {
+			return err
+		}
+		for _, ipAddressList := range ipAddressLists {
+			for _, entry := range ipAddressList.Addresses {
+				entryRange, ok := parseIPAddressListEntry(entry)
+				if ok {
+					addressListRanges = append(addressListRanges, entryRange)
+				}
+			}
+		}
+	}
+
+	var (
+		domainBlocks     []networkDomainPublicIPBlocks
+		totalBlockCount  int
+		unusedBlockCount int
+	)
+	for _, networkDomain := range networkDomains {
+		blocks, err := findUnusedPublicIPBlocks(apiClient, networkDomain, addressListRanges)
+		if err != nil {
+			return err
+		}
+
+		totalBlockCount += len(blocks.PublicIPBlocks)
+		unusedBlockCount += len(blocks.UnusedBlocks)
+		domainBlocks = append(domainBlocks, *blocks)
+	}
+
+	fmt.Fprintf(console, "Public IP blocks in %s:\n", scope)
+	for _, blocks := range domainBlocks {
+		if len(blocks.PublicIPBlocks) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(console, "  network domain '%s' ('%s') in datacenter '%s': %d block(s), %d unused\n",
+			blocks.NetworkDomain.Name,
+			blocks.NetworkDomain.ID,
+			blocks.NetworkDomain.DatacenterID,
+			len(blocks.PublicIPBlocks),
+			len(blocks.UnusedBlocks),
+		)
+		for _, unusedBlock := range blocks.UnusedBlocks {
+			fmt.Fprintf(console, "    unused: '%s' (%s, %d addresses)\n",
+				unusedBlock.ID,
+				unusedBlock.BaseIP,
+				unusedBlock.Size,
+			)
+		}
+	}
+	fmt.Fprintf(console, "Total: %d block(s) in %d network domain(s), %d unused.\n",
+		totalBlockCount,
+		len(networkDomains),
+		unusedBlockCount,
+	)
+
+	if unusedBlockCount == 0 || options.ReclaimIPs.DryRun {
+		return nil
+	}
+
+	if !options.Force {
+		fmt.Fprintf(console, "WARNING - about to remove %d unused public IP block(s) in %s. Are you sure you want to proceed?\n",
+			unusedBlockCount,
+			scope,
+		)
+		confirmed, err := confirm()
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			return errNotConfirmed
+		}
+	}
+
+	for _, blocks := range domainBlocks {
+		for _, unusedBlock := range blocks.UnusedBlocks {
+			logger.Printf("Removing public IP block '%s' (%s) from network domain '%s'...",
+				unusedBlock.ID,
+				unusedBlock.BaseIP,
+				blocks.NetworkDomain.ID,
+			)
+
+			call := beginAPICall("delete", "publicIPBlock", unusedBlock.ID, "")
+			err = apiClient.RemovePublicIPBlock(unusedBlock.ID)
+			call.End(err)
+			if err != nil {
+				return err
+			}
+
+			logger.Printf("Removed public IP block '%s' (%s) from network domain '%s'.",
+				unusedBlock.ID,
+				unusedBlock.BaseIP,
+				blocks.NetworkDomain.ID,
+			)
+		}
+	}
+
+	return nil
+}
+
+// Find the public IP blocks in a network domain that are not used by NAT rules, virtual listeners, or IP address lists.
+func findUnusedPublicIPBlocks(apiClient *compute.Client, networkDomain compute.NetworkDomain, addressListRanges []ipv4Range) (*networkDomainPublicIPBlocks, error) {
+	log.Printf("Checking public IP blocks in network domain '%s'...", networkDomain.ID)
+
+	blocks := &networkDomainPublicIPBlocks{
+		NetworkDomain: networkDomain,
+	}
+
+	publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	blocks.PublicIPBlocks = publicIPBlocks
+	if len(publicIPBlocks) == 0 {
+		return blocks, nil
+	}
+
+	natRules, err := listNATRules(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	virtualListeners, err := listVirtualListeners(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks.UnusedBlocks = selectUnusedPublicIPBlocks(publicIPBlocks, natRules, virtualListeners, addressListRanges)
+
+	return blocks, nil
+}
+
+// Select the public IP blocks whose addresses are not used by any of the specified NAT rules, virtual listeners, or IP
+// address list entries.
+//
+// Address list entries that cover every address (e.g. 0.0.0.0/0) do not count as using a block (see refersTo).
+func selectUnusedPublicIPBlocks(publicIPBlocks []compute.PublicIPBlock, natRules []compute.NATRule, virtualListeners []compute.VirtualListener, addressListRanges []ipv4Range) []compute.PublicIPBlock {
+	var usedAddresses []ipv4Range
+	for _, natRule := range natRules {
+		usedRange, ok := parseIPv4Prefix(natRule.ExternalIPAddress, nil)
+		if ok {
+			usedAddresses = append(usedAddresses, usedRange)
+		}
+	}
+	for _, virtualListener := range virtualListeners {
+		usedRange, ok := parseIPv4Prefix(virtualListener.ListenerIPAddress, nil)
+		if ok {
+			usedAddresses = append(usedAddresses, usedRange)
+		}
+	}
+
+	var unusedBlocks []compute.PublicIPBlock
+	for _, publicIPBlock := range publicIPBlocks {
+		first, ok := parseIPv4(publicIPBlock.BaseIP)
+		if !ok {
+			continue
+		}
+
+		blockRange := []ipv4Range{{
+			First: first,
+			Last:  first + uint32(publicIPBlock.Size) - 1,
+		}}
+		if overlapsAny(blockRange[0], usedAddresses) || anyRefersTo(addressListRanges, blockRange) {
+			continue
+		}
+
+		unusedBlocks = append(unusedBlocks, publicIPBlock)
+	}
+
+	return unusedBlocks
+}
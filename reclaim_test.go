@@ -0,0 +1,76 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestSelectUnusedPublicIPBlocks(t *testing.T) {
+	publicIPBlocks := []compute.PublicIPBlock{
+		{ID: "nat-block", BaseIP: "168.128.1.10", Size: 2},
+		{ID: "vip-block", BaseIP: "168.128.1.20", Size: 2},
+		{ID: "listed-block", BaseIP: "168.128.1.30", Size: 2},
+		{ID: "unused-block", BaseIP: "168.128.1.40", Size: 2},
+	}
+	natRules := []compute.NATRule{
+		{ID: "nat1", InternalIPAddress: "10.0.0.10", ExternalIPAddress: "168.128.1.11"},
+	}
+	virtualListeners := []compute.VirtualListener{
+		{ID: "listener1", ListenerIPAddress: "168.128.1.20"},
+	}
+
+	testCases := []struct {
+		Name              string
+		AddressListRanges []ipv4Range
+		Expected          []string
+	}{
+		{
+			Name:     "no address lists",
+			Expected: []string{"listed-block", "unused-block"},
+		},
+		{
+			Name:              "address list entry",
+			AddressListRanges: []ipv4Range{testIPv4Range(t, "168.128.1.31", "168.128.1.31")},
+			Expected:          []string{"unused-block"},
+		},
+		{
+			Name:              "address list prefix covering several blocks",
+			AddressListRanges: []ipv4Range{testIPv4Range(t, "168.128.1.0", "168.128.1.255")},
+			Expected:          nil,
+		},
+		{
+			Name:              "address list entry matching any address",
+			AddressListRanges: []ipv4Range{testIPv4Range(t, "0.0.0.0", "255.255.255.255")},
+			Expected:          []string{"listed-block", "unused-block"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		unusedBlocks := selectUnusedPublicIPBlocks(publicIPBlocks, natRules, virtualListeners, testCase.AddressListRanges)
+
+		var actual []string
+		for _, unusedBlock := range unusedBlocks {
+			actual = append(actual, unusedBlock.ID)
+		}
+		if !equalStrings(actual, testCase.Expected) {
+			t.Errorf("%s: expected unused blocks %v, but got %v", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// An externalReference is something outside the target network domain that refers to one of its public IP addresses.
+type externalReference struct {
+	NetworkDomainID   string
+	NetworkDomainName string
+	ResourceType      string
+	ResourceID        string
+	ResourceName      string
+	Address           string
+}
+
+// String returns a description of the external reference.
+func (reference externalReference) String() string {
+	return fmt.Sprintf("%s '%s' ('%s') in network domain '%s' ('%s') refers to '%s'",
+		reference.ResourceType,
+		reference.ResourceName,
+		reference.ResourceID,
+		reference.NetworkDomainName,
+		reference.NetworkDomainID,
+		reference.Address,
+	)
+}
+
+// An ipv4Range is an inclusive range of IPv4 addresses.
+type ipv4Range struct {
+	First uint32
+	Last  uint32
+}
+
+// Overlaps determines whether the range overlaps with another range.
+func (addressRange ipv4Range) Overlaps(other ipv4Range) bool {
+	return addressRange.First <= other.Last && other.First <= addressRange.Last
+}
+
+// IsAny determines whether the range covers every IPv4 address (e.g. 0.0.0.0/0).
+func (addressRange ipv4Range) IsAny() bool {
+	return addressRange.First == 0 && addressRange.Last == ^uint32(0)
+}
+
+// Find firewall rules and IP address lists in other network domains (in the same datacenter) that refer to the target network domain's public IP addresses.
+func findExternalReferences(apiClient *compute.Client, networkDomain *compute.NetworkDomain) ([]externalReference, error) {
+	log.Printf("Scanning datacenter '%s' for references to public IP addresses in network domain '%s'...",
+		networkDomain.DatacenterID,
+		networkDomain.ID,
+	)
+
+	publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomain.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(publicIPBlocks) == 0 {
+		return nil, nil
+	}
+
+	var publicIPRanges []ipv4Range
+	for _, publicIPBlock := range publicIPBlocks {
+		first, ok := parseIPv4(publicIPBlock.BaseIP)
+		if !ok {
+			continue
+		}
+
+		publicIPRanges = append(publicIPRanges, ipv4Range{
+			First: first,
+			Last:  first + uint32(publicIPBlock.Size) - 1,
+		})
+	}
+
+	otherNetworkDomains, err := listNetworkDomains(apiClient, networkDomain.DatacenterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var references []externalReference
+	for _, otherNetworkDomain := range otherNetworkDomains {
+		if otherNetworkDomain.ID == networkDomain.ID {
+			continue
+		}
+
+		firewallRules, err := listFirewallRules(apiClient, otherNetworkDomain.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, firewallRule := range firewallRules {
+			for _, scope := range []compute.FirewallRuleScope{firewallRule.Source, firewallRule.Destination} {
+				if scope.IPAddress == nil {
+					continue
+				}
+
+				scopeRange, ok := parseIPv4Prefix(scope.IPAddress.Address, scope.IPAddress.PrefixSize)
+				if !ok || !refersTo(scopeRange, publicIPRanges) {
+					continue
+				}
+
+				references = append(references, externalReference{
+					NetworkDomainID:   otherNetworkDomain.ID,
+					NetworkDomainName: otherNetworkDomain.Name,
+					ResourceType:      "Firewall rule",
+					ResourceID:        firewallRule.ID,
+					ResourceName:      firewallRule.Name,
+					Address:           formatPrefix(scope.IPAddress.Address, scope.IPAddress.PrefixSize),
+				})
+			}
+		}
+
+		ipAddressLists, err := listIPAddressLists(apiClient, otherNetworkDomain.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ipAddressList := range ipAddressLists {
+			for _, entry := range ipAddressList.Addresses {
+				entryRange, ok := parseIPAddressListEntry(entry)
+				if !ok || !refersTo(entryRange, publicIPRanges) {
+					continue
+				}
+
+				address := formatPrefix(entry.Begin, entry.PrefixSize)
+				if entry.End != nil {
+					address = entry.Begin + "-" + *entry.End
+				}
+
+				references = append(references, externalReference{
+					NetworkDomainID:   otherNetworkDomain.ID,
+					NetworkDomainName: otherNetworkDomain.Name,
+					ResourceType:      "IP address list",
+					ResourceID:        ipAddressList.ID,
+					ResourceName:      ipAddressList.Name,
+					Address:           address,
+				})
+			}
+		}
+	}
+
+	log.Printf("Found %d external reference(s) to public IP addresses in network domain '%s'.",
+		len(references),
+		networkDomain.ID,
+	)
+
+	return references, nil
+}
+
+// Determine whether an address range refers to any of the specified ranges.
+//
+// Ranges that cover every address (e.g. 0.0.0.0/0) are not specific to any network domain, so they are not treated as references.
+func refersTo(addressRange ipv4Range, ranges []ipv4Range) bool {
+	if addressRange.IsAny() {
+		return false
+	}
+
+	return overlapsAny(addressRange, ranges)
+}
+
+// Determine whether any of the specified address ranges refers to any of the target ranges (see refersTo).
+func anyRefersTo(addressRanges []ipv4Range, targetRanges []ipv4Range) bool {
+	for _, addressRange := range addressRanges {
+		if refersTo(addressRange, targetRanges) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func overlapsAny(addressRange ipv4Range, ranges []ipv4Range) bool {
+	for _, other := range ranges {
+		if addressRange.Overlaps(other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseIPAddressListEntry(entry compute.IPAddressListEntry) (ipv4Range, bool) {
+	if entry.End == nil {
+		return parseIPv4Prefix(entry.Begin, entry.PrefixSize)
+	}
+
+	first, ok := parseIPv4(entry.Begin)
+	if !ok {
+		return ipv4Range{}, false
+	}
+	last, ok := parseIPv4(*entry.End)
+	if !ok {
+		return ipv4Range{}, false
+	}
+
+	return ipv4Range{First: first, Last: last}, true
+}
+
+// Parse an IPv4 address with an optional prefix size (e.g. 10.0.0.0/24) into an address range.
+func parseIPv4Prefix(address string, prefixSize *int) (ipv4Range, bool) {
+	first, ok := parseIPv4(address)
+	if !ok {
+		return ipv4Range{}, false
+	}
+	if prefixSize == nil || *prefixSize >= 32 {
+		return ipv4Range{First: first, Last: first}, true
+	}
+	if *prefixSize <= 0 {
+		return ipv4Range{First: 0, Last: ^uint32(0)}, true
+	}
+
+	hostMask := ^uint32(0) >> uint(*prefixSize)
+
+	return ipv4Range{
+		First: first &^ hostMask,
+		Last:  first | hostMask,
+	}, true
+}
+
+// Parse an IPv4 address into an integer (returns false if the address is not a valid IPv4 address).
+func parseIPv4(address string) (uint32, bool) {
+	ip := net.ParseIP(address).To4()
+	if ip == nil {
+		return 0, false
+	}
+
+	return binary.BigEndian.Uint32(ip), true
+}
+
+func formatPrefix(address string, prefixSize *int) string {
+	if prefixSize == nil {
+		return address
+	}
+
+	return fmt.Sprintf("%s/%d", address, *prefixSize)
+}
@@ -0,0 +1,127 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestParseIPv4Prefix(t *testing.T) {
+	prefix := func(size int) *int {
+		return &size
+	}
+
+	testCases := []struct {
+		Address    string
+		PrefixSize *int
+		First      string
+		Last       string
+		Invalid    bool
+	}{
+		{Address: "168.128.1.10", First: "168.128.1.10", Last: "168.128.1.10"},
+		{Address: "168.128.1.10", PrefixSize: prefix(32), First: "168.128.1.10", Last: "168.128.1.10"},
+		{Address: "168.128.1.10", PrefixSize: prefix(24), First: "168.128.1.0", Last: "168.128.1.255"},
+		{Address: "168.128.1.10", PrefixSize: prefix(31), First: "168.128.1.10", Last: "168.128.1.11"},
+		{Address: "10.1.2.3", PrefixSize: prefix(8), First: "10.0.0.0", Last: "10.255.255.255"},
+		{Address: "0.0.0.0", PrefixSize: prefix(0), First: "0.0.0.0", Last: "255.255.255.255"},
+		{Address: "ANY", Invalid: true},
+		{Address: "2001:db8::1", Invalid: true},
+	}
+
+	for _, testCase := range testCases {
+		actual, ok := parseIPv4Prefix(testCase.Address, testCase.PrefixSize)
+		description := formatPrefix(testCase.Address, testCase.PrefixSize)
+		if testCase.Invalid {
+			if ok {
+				t.Errorf("%s: expected an invalid address, but got %v", description, actual)
+			}
+
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: unexpected invalid address", description)
+
+			continue
+		}
+
+		expected := testIPv4Range(t, testCase.First, testCase.Last)
+		if actual != expected {
+			t.Errorf("%s: expected %s-%s, but got %v", description, testCase.First, testCase.Last, actual)
+		}
+	}
+}
+
+func TestIPv4RangeOverlaps(t *testing.T) {
+	publicIPs := testIPv4Range(t, "168.128.1.10", "168.128.1.11")
+
+	testCases := []struct {
+		First    string
+		Last     string
+		Overlaps bool
+	}{
+		{"168.128.1.10", "168.128.1.10", true},
+		{"168.128.1.11", "168.128.1.20", true},
+		{"168.128.1.0", "168.128.1.255", true},
+		{"168.128.1.0", "168.128.1.9", false},
+		{"168.128.1.12", "168.128.1.12", false},
+	}
+
+	for _, testCase := range testCases {
+		other := testIPv4Range(t, testCase.First, testCase.Last)
+		if actual := other.Overlaps(publicIPs); actual != testCase.Overlaps {
+			t.Errorf("%s-%s: expected overlap %t, but got %t", testCase.First, testCase.Last, testCase.Overlaps, actual)
+		}
+		if actual := publicIPs.Overlaps(other); actual != testCase.Overlaps {
+			t.Errorf("%s-%s: overlap is not symmetric", testCase.First, testCase.Last)
+		}
+	}
+}
+
+func TestRefersToIgnoresAnyAddress(t *testing.T) {
+	publicIPRanges := []ipv4Range{testIPv4Range(t, "168.128.1.10", "168.128.1.11")}
+
+	zeroPrefix := 0
+	anyRange, _ := parseIPv4Prefix("0.0.0.0", &zeroPrefix)
+	if refersTo(anyRange, publicIPRanges) {
+		t.Error("Expected 0.0.0.0/0 not to be treated as an external reference")
+	}
+
+	end := "255.255.255.255"
+	listRange, _ := parseIPAddressListEntry(compute.IPAddressListEntry{Begin: "0.0.0.0", End: &end})
+	if refersTo(listRange, publicIPRanges) {
+		t.Error("Expected 0.0.0.0-255.255.255.255 not to be treated as an external reference")
+	}
+
+	if !refersTo(testIPv4Range(t, "168.128.1.11", "168.128.1.11"), publicIPRanges) {
+		t.Error("Expected 168.128.1.11 to be treated as an external reference")
+	}
+}
+
+func testIPv4Range(t *testing.T, first string, last string) ipv4Range {
+	firstAddress, ok := parseIPv4(first)
+	if !ok {
+		t.Fatalf("Invalid test address '%s'", first)
+	}
+	lastAddress, ok := parseIPv4(last)
+	if !ok {
+		t.Fatalf("Invalid test address '%s'", last)
+	}
+
+	return ipv4Range{First: firstAddress, Last: lastAddress}
+}
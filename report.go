@@ -0,0 +1,402 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// The report for the nuke currently in progress (if any).
+var currentReport *runReport
+
+// runReport records the outcome of every action performed on a resource while nuking a network domain.
+//
+// It is safe to update from multiple goroutines.
+type runReport struct {
+	lock    sync.Mutex
+	started time.Time
+
+	NetworkDomainID         string           `json:"networkDomainId"`
+	NetworkDomainName       string           `json:"networkDomainName"`
+	StartedAt               string           `json:"startedAt"`
+	FinishedAt              string           `json:"finishedAt"`
+	Duration                float64          `json:"durationSeconds"`
+	Outcome                 string           `json:"outcome"`
+	Error                   string           `json:"error,omitempty"`
+	InventoryFile           string           `json:"inventoryFile,omitempty"`
+	EstimatedMonthlySavings *costEstimate    `json:"estimatedMonthlySavings,omitempty"`
+	Stages                  []stageReport    `json:"stages"`
+	Resources               []resourceReport `json:"resources"`
+
+	// Customer images cloned from servers before they were destroyed (--backup-servers).
+	ServerBackups []serverBackup `json:"serverBackups,omitempty"`
+
+	// Cloud Backup subscriptions removed from servers (and the estimated monthly cost saved).
+	BackupSubscriptions    []backupSubscription `json:"backupSubscriptions,omitempty"`
+	BackupMonthlySavings   float64              `json:"backupMonthlySavings,omitempty"`
+	BackupUnknownCostCount int                  `json:"backupUnknownCostCount,omitempty"`
+}
+
+// A stageReport summarises the outcome of a stage.
+type stageReport struct {
+	Stage     string  `json:"stage"`
+	Outcome   string  `json:"outcome"`
+	Succeeded int     `json:"succeeded"`
+	Failed    int     `json:"failed"`
+	Duration  float64 `json:"durationSeconds"`
+}
+
+// A resourceReport records the outcome of an action performed on a resource.
+type resourceReport struct {
+	Stage        string  `json:"stage"`
+	ResourceType string  `json:"resourceType"`
+	ResourceID   string  `json:"resourceId"`
+	ResourceName string  `json:"resourceName,omitempty"`
+	Action       string  `json:"action"`
+	Outcome      string  `json:"outcome"`
+	Error        string  `json:"error,omitempty"`
+	Duration     float64 `json:"durationSeconds"`
+}
+
+// Outcomes recorded in run reports.
+const (
+	outcomeSucceeded = "succeeded"
+	outcomeFailed    = "failed"
+
+	// The resource had already been deleted (only when ignoring missing resources).
+	outcomeAlreadyDeleted = "already-deleted"
+)
+
+// Create a new run report for the specified network domain.
+func newRunReport(networkDomainID string, networkDomainName string) *runReport {
+	started := time.Now()
+
+	return &runReport{
+		started:           started,
+		NetworkDomainID:   networkDomainID,
+		NetworkDomainName: networkDomainName,
+		StartedAt:         started.UTC().Format(time.RFC3339),
+		Outcome:           outcomeSucceeded,
+	}
+}
+
+// RecordAction records the outcome of an action performed on a resource.
+func (report *runReport) RecordAction(event logEvent, duration time.Duration, outcome string, err error) {
+	resource := resourceReport{
+		Stage:        event.Stage,
+		ResourceType: event.ResourceType,
+		ResourceID:   event.ResourceID,
+		ResourceName: event.ResourceName,
+		Action:       event.Action,
+		Outcome:      outcome,
+		Duration:     duration.Seconds(),
+	}
+	if err != nil {
+		resource.Error = err.Error()
+	}
+
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	report.Resources = append(report.Resources, resource)
+}
+
+// AddServerBackup records a customer image cloned from a server before it was destroyed.
+func (report *runReport) AddServerBackup(backup serverBackup) {
+	if report == nil {
+		return
+	}
+
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	report.ServerBackups = append(report.ServerBackups, backup)
+}
+
+// AddBackupSubscription records a Cloud Backup subscription that was removed from a server.
+func (report *runReport) AddBackupSubscription(subscription backupSubscription) {
+	if report == nil {
+		return
+	}
+
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	report.BackupSubscriptions = append(report.BackupSubscriptions, subscription)
+	report.BackupMonthlySavings, report.BackupUnknownCostCount = totalBackupSubscriptionCost(report.BackupSubscriptions)
+}
+
+// RecordStage records the outcome of a stage (and totals the outcomes of the actions it performed).
+func (report *runReport) RecordStage(stageName string, duration time.Duration, err error) stageReport {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	stage := stageReport{
+		Stage:    stageName,
+		Outcome:  outcomeSucceeded,
+		Duration: duration.Seconds(),
+	}
+	if err != nil {
+		stage.Outcome = outcomeFailed
+	}
+	for _, resource := range report.Resources {
+		if resource.Stage != stageName {
+			continue
+		}
+
+		if resource.Outcome == outcomeFailed {
+			stage.Failed++
+		} else {
+			stage.Succeeded++
+		}
+	}
+
+	report.Stages = append(report.Stages, stage)
+
+	return stage
+}
+
+// Finish marks the run as complete.
+func (report *runReport) Finish(err error) {
+	report.lock.Lock()
+	defer report.lock.Unlock()
+
+	finished := time.Now()
+	report.FinishedAt = finished.UTC().Format(time.RFC3339)
+	report.Duration = finished.Sub(report.started).Seconds()
+	if err != nil {
+		report.Outcome = outcomeFailed
+		report.Error = err.Error()
+	}
+}
+
+// Display the run report.
+func showReport(report *runReport) {
+	writeReportText(console, report)
+}
+
+// Write the run report in human-readable form.
+func writeReportText(writer io.Writer, report *runReport) {
+	fmt.Fprintf(writer, "Report for network domain '%s' (Id = '%s'): %s in %s.\n",
+		report.NetworkDomainName,
+		report.NetworkDomainID,
+		report.Outcome,
+		formatSeconds(report.Duration),
+	)
+
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	if len(report.Resources) > 0 {
+		fmt.Fprintln(table, "  STAGE\tRESOURCE\tACTION\tOUTCOME\tELAPSED\tERROR")
+		for _, resource := range report.Resources {
+			fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+				resource.Stage,
+				describeReportedResource(resource),
+				resource.Action,
+				resource.Outcome,
+				formatSeconds(resource.Duration),
+				resource.Error,
+			)
+		}
+		fmt.Fprintln(table, "\t\t\t\t\t")
+	}
+
+	fmt.Fprintln(table, "  STAGE\tSUCCEEDED\tFAILED\tOUTCOME\tELAPSED\t")
+	for _, stage := range report.Stages {
+		fmt.Fprintf(table, "  %s\t%d\t%d\t%s\t%s\t\n",
+			stage.Stage,
+			stage.Succeeded,
+			stage.Failed,
+			stage.Outcome,
+			formatSeconds(stage.Duration),
+		)
+	}
+	table.Flush()
+
+	if report.EstimatedMonthlySavings != nil {
+		fmt.Fprintf(writer, "Estimated monthly savings: %s.\n", report.EstimatedMonthlySavings)
+	}
+	if report.Error != "" {
+		fmt.Fprintf(writer, "Error: %s\n", report.Error)
+	}
+}
+
+// Write the run report in Markdown form.
+func writeReportMarkdown(writer io.Writer, report *runReport) {
+	fmt.Fprintf(writer, "# Report for network domain '%s'\n\n", report.NetworkDomainName)
+	fmt.Fprintf(writer, "* Network domain Id: `%s`\n", report.NetworkDomainID)
+	fmt.Fprintf(writer, "* Started: %s\n", report.StartedAt)
+	fmt.Fprintf(writer, "* Finished: %s\n", report.FinishedAt)
+	fmt.Fprintf(writer, "* Elapsed: %s\n", formatSeconds(report.Duration))
+	fmt.Fprintf(writer, "* Outcome: %s\n", report.Outcome)
+	if report.Error != "" {
+		fmt.Fprintf(writer, "* Error: %s\n", escapeMarkdownTableCell(report.Error))
+	}
+	if report.InventoryFile != "" {
+		fmt.Fprintf(writer, "* Inventory: `%s`\n", report.InventoryFile)
+	}
+	if report.EstimatedMonthlySavings != nil {
+		fmt.Fprintf(writer, "* Estimated monthly savings: %s\n", report.EstimatedMonthlySavings)
+	}
+	if len(report.BackupSubscriptions) > 0 {
+		fmt.Fprintf(writer, "* Cloud Backup savings: %.2f per month", report.BackupMonthlySavings)
+		if report.BackupUnknownCostCount > 0 {
+			fmt.Fprintf(writer, " (cost unknown for %d subscription(s))", report.BackupUnknownCostCount)
+		}
+		fmt.Fprintln(writer)
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "## Stages")
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "| Stage | Succeeded | Failed | Outcome | Elapsed |")
+	fmt.Fprintln(writer, "|---|---|---|---|---|")
+	for _, stage := range report.Stages {
+		fmt.Fprintf(writer, "| %s | %d | %d | %s | %s |\n",
+			stage.Stage,
+			stage.Succeeded,
+			stage.Failed,
+			stage.Outcome,
+			formatSeconds(stage.Duration),
+		)
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "## Resources")
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "| Stage | Resource | Action | Outcome | Elapsed | Error |")
+	fmt.Fprintln(writer, "|---|---|---|---|---|---|")
+	for _, resource := range report.Resources {
+		fmt.Fprintf(writer, "| %s | %s | %s | %s | %s | %s |\n",
+			resource.Stage,
+			escapeMarkdownTableCell(describeReportedResource(resource)),
+			resource.Action,
+			resource.Outcome,
+			formatSeconds(resource.Duration),
+			escapeMarkdownTableCell(resource.Error),
+		)
+	}
+
+	if len(report.ServerBackups) > 0 {
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "## Server backups")
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "| Server | Customer image |")
+		fmt.Fprintln(writer, "|---|---|")
+		for _, backup := range report.ServerBackups {
+			fmt.Fprintf(writer, "| %s (`%s`) | %s (`%s`) |\n",
+				escapeMarkdownTableCell(backup.ServerName),
+				backup.ServerID,
+				escapeMarkdownTableCell(backup.ImageName),
+				backup.ImageID,
+			)
+		}
+	}
+
+	if len(report.BackupSubscriptions) > 0 {
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "## Cloud Backup")
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "| Server | Service plan | Clients | Monthly cost |")
+		fmt.Fprintln(writer, "|---|---|---|---|")
+		for _, subscription := range report.BackupSubscriptions {
+			monthlyCost := "unknown"
+			if subscription.MonthlyCost != nil {
+				monthlyCost = fmt.Sprintf("%.2f", *subscription.MonthlyCost)
+			}
+
+			fmt.Fprintf(writer, "| %s (`%s`) | %s | %d | %s |\n",
+				escapeMarkdownTableCell(subscription.ServerName),
+				subscription.ServerID,
+				escapeMarkdownTableCell(subscription.ServicePlan),
+				subscription.ClientCount,
+				monthlyCost,
+			)
+		}
+	}
+}
+
+// Write the run report to a file (in JSON or Markdown format, depending on the file's extension).
+func writeReport(report *runReport, reportFile string) error {
+	var (
+		data []byte
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(reportFile)) {
+	case ".json":
+		report.lock.Lock()
+		data, err = json.MarshalIndent(report, "", "  ")
+		report.lock.Unlock()
+	case ".md":
+		buffer := &bytes.Buffer{}
+		writeReportMarkdown(buffer, report)
+		data = buffer.Bytes()
+	default:
+		err = fmt.Errorf("Unsupported report file '%s' (must end with .json or .md).", reportFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(reportFile, data, 0600)
+}
+
+// Determine the path of the report file for a network domain.
+//
+// When a single run may nuke more than one network domain, the network domain name is appended to the file name.
+func reportFilePath(reportFile string, networkDomainName string, options programOptions) string {
+	if options.Command == "" {
+		return reportFile
+	}
+
+	extension := filepath.Ext(reportFile)
+
+	return fmt.Sprintf("%s-%s%s",
+		strings.TrimSuffix(reportFile, extension),
+		unsafeFileNameCharacters.ReplaceAllString(networkDomainName, "_"),
+		extension,
+	)
+}
+
+// Describe a reported resource (e.g. "server 'web1' ('id1')").
+func describeReportedResource(resource resourceReport) string {
+	if resource.ResourceName == "" {
+		return fmt.Sprintf("%s '%s'", resource.ResourceType, resource.ResourceID)
+	}
+
+	return fmt.Sprintf("%s '%s' ('%s')", resource.ResourceType, resource.ResourceName, resource.ResourceID)
+}
+
+// Format a number of seconds as a duration (e.g. "1m32.5s").
+func formatSeconds(seconds float64) string {
+	return (time.Duration(seconds*float64(time.Second)) / time.Millisecond * time.Millisecond).String()
+}
+
+// Escape characters that would break a cell in a Markdown table.
+func escapeMarkdownTableCell(value string) string {
+	value = strings.Replace(value, "|", "\\|", -1)
+
+	return strings.Replace(value, "\n", " ", -1)
+}
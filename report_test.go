@@ -0,0 +1,72 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportIncludesBackups(t *testing.T) {
+	cost := 12.5
+	report := newRunReport("domain-id", "my-domain")
+	report.InventoryFile = "inventory/my-domain.json"
+	report.AddServerBackup(serverBackup{ServerID: "server1", ServerName: "web-1", ImageID: "image1", ImageName: "my-domain-web-1"})
+	report.AddBackupSubscription(backupSubscription{ServerID: "server1", ServerName: "web-1", ServicePlan: "Essentials", ClientCount: 1, MonthlyCost: &cost})
+	report.AddBackupSubscription(backupSubscription{ServerID: "server2", ServerName: "web-2", ServicePlan: "Unknown", ClientCount: 2})
+	report.Finish(nil)
+
+	if report.BackupMonthlySavings != 12.5 || report.BackupUnknownCostCount != 1 {
+		t.Errorf("Expected Cloud Backup savings of 12.50 (1 unknown), but got %.2f (%d unknown)", report.BackupMonthlySavings, report.BackupUnknownCostCount)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"inventoryFile":"inventory/my-domain.json"`, `"imageId":"image1"`, `"servicePlan":"Essentials"`, `"backupMonthlySavings":12.5`} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("Expected JSON report to contain %s, but got %s", expected, data)
+		}
+	}
+
+	markdown := &bytes.Buffer{}
+	writeReportMarkdown(markdown, report)
+	for _, expected := range []string{"* Inventory: `inventory/my-domain.json`", "## Server backups", "my-domain-web-1 (`image1`)", "* Cloud Backup savings: 12.50 per month (cost unknown for 1 subscription(s))"} {
+		if !strings.Contains(markdown.String(), expected) {
+			t.Errorf("Expected Markdown report to contain '%s', but got:\n%s", expected, markdown)
+		}
+	}
+}
+
+func TestReportAlreadyDeleted(t *testing.T) {
+	report := newRunReport("domain-id", "my-domain")
+	report.RecordAction(logEvent{Stage: "natrules", ResourceType: "natRule", ResourceID: "rule1", Action: "delete"}, time.Second, outcomeAlreadyDeleted, nil)
+	report.RecordAction(logEvent{Stage: "natrules", ResourceType: "natRule", ResourceID: "rule2", Action: "delete"}, time.Second, outcomeFailed, errors.New("boom"))
+	report.RecordStage("natrules", 2*time.Second, nil)
+
+	if len(report.Resources) != 2 || report.Resources[0].Outcome != outcomeAlreadyDeleted {
+		t.Fatalf("Expected the first resource to be recorded as already deleted, but got %+v", report.Resources)
+	}
+	if stage := report.Stages[0]; stage.Succeeded != 1 || stage.Failed != 1 {
+		t.Errorf("Expected 1 succeeded and 1 failed (already-deleted counts as succeeded), but got %+v", stage)
+	}
+}
@@ -17,7 +17,6 @@
 package main
 
 import (
-	"fmt"
 	"log"
 
 	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
@@ -35,7 +34,10 @@ func resolveNetworkDomain(apiClient *compute.Client, options programOptions) (ne
 	}
 
 	if networkDomain == nil {
-		err = fmt.Errorf("Unable to find network domain '%s' in datacenter '%s'", options.NetworkDomain, options.Datacenter)
+		err = &networkDomainNotFoundError{
+			Name:         options.NetworkDomain,
+			DatacenterID: options.Datacenter,
+		}
 	}
 
 	return
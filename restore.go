@@ -0,0 +1,461 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+	"gopkg.in/yaml.v2"
+)
+
+// The environment variable containing the administrator password for restored placeholder servers.
+const serverPasswordVariable = "NIFO_SERVER_PASSWORD"
+
+// restoreOptions represents the options for the "restore" command.
+type restoreOptions struct {
+	Args struct {
+		InventoryFile string `positional-arg-name:"inventory" description:"The inventory file (in JSON or YAML format) captured before the network domain was nuked."`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// Recreate the skeleton of a nuked network domain from its inventory.
+//
+// The network domain, VLANs, public IP blocks, NAT rules, and firewall rules are recreated; servers are only recreated
+// (as placeholders, and only if their source images are still available) when an administrator password is supplied.
+func restore(apiClient *compute.Client, options programOptions) error {
+	domainInventory, err := readInventory(options.Restore.Args.InventoryFile)
+	if err != nil {
+		return err
+	}
+
+	datacenterID := options.Datacenter
+	if datacenterID == "" {
+		datacenterID = domainInventory.NetworkDomain.DatacenterID
+	}
+	networkDomainName := options.NetworkDomain
+	if networkDomainName == "" {
+		networkDomainName = domainInventory.NetworkDomain.Name
+	}
+
+	existingNetworkDomain, err := apiClient.GetNetworkDomainByName(networkDomainName, datacenterID)
+	if err != nil {
+		return err
+	}
+	if existingNetworkDomain != nil {
+		return fmt.Errorf("Network domain '%s' already exists in datacenter '%s' (Id = '%s').",
+			networkDomainName,
+			datacenterID,
+			existingNetworkDomain.ID,
+		)
+	}
+
+	logger.Printf("Restoring network domain '%s' in datacenter '%s' from '%s'...",
+		networkDomainName,
+		datacenterID,
+		options.Restore.Args.InventoryFile,
+	)
+
+	networkDomainID, err := restoreNetworkDomain(apiClient, domainInventory, networkDomainName, datacenterID)
+	if err != nil {
+		return err
+	}
+
+	vlanIDs, err := restoreVLANs(apiClient, networkDomainID, domainInventory.VLANs)
+	if err != nil {
+		return err
+	}
+
+	err = restorePublicIPBlocks(apiClient, networkDomainID, len(domainInventory.PublicIPBlocks))
+	if err != nil {
+		return err
+	}
+
+	externalIPAddresses, err := restoreNATRules(apiClient, networkDomainID, domainInventory.NATRules)
+	if err != nil {
+		return err
+	}
+
+	err = restoreFirewallRules(apiClient, networkDomainID, domainInventory.FirewallRules, externalIPAddresses)
+	if err != nil {
+		return err
+	}
+
+	serverPassword := os.Getenv(serverPasswordVariable)
+	if serverPassword != "" {
+		err = restoreServers(apiClient, networkDomainID, domainInventory.Servers, vlanIDs, serverPassword)
+		if err != nil {
+			return err
+		}
+	} else if len(domainInventory.Servers) > 0 {
+		logger.Printf("Not recreating %d server(s) because no administrator password was specified (set the %s environment variable).",
+			len(domainInventory.Servers),
+			serverPasswordVariable,
+		)
+	}
+
+	logger.Printf("Restored network domain '%s' (Id = '%s').", networkDomainName, networkDomainID)
+
+	return nil
+}
+
+// Read an inventory (in JSON or YAML format) from a file.
+func readInventory(inventoryFile string) (*inventory, error) {
+	data, err := ioutil.ReadFile(inventoryFile)
+	if err != nil {
+		return nil, err
+	}
+
+	domainInventory := &inventory{}
+	switch strings.ToLower(filepath.Ext(inventoryFile)) {
+	case ".json":
+		err = json.Unmarshal(data, domainInventory)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, domainInventory)
+	default:
+		err = fmt.Errorf("Cannot read inventory '%s' (only JSON and YAML inventories can be restored).", inventoryFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read inventory '%s' (%s).", inventoryFile, strings.TrimSuffix(err.Error(), "."))
+	}
+
+	return domainInventory, nil
+}
+
+func restoreNetworkDomain(apiClient *compute.Client, domainInventory *inventory, networkDomainName string, datacenterID string) (string, error) {
+	logger.Printf("Creating network domain '%s'...", networkDomainName)
+
+	call := beginAPICall("deploy", "networkDomain", "", networkDomainName)
+	networkDomainID, err := apiClient.DeployNetworkDomain(
+		networkDomainName,
+		domainInventory.NetworkDomain.Description,
+		domainInventory.NetworkDomain.Type,
+		datacenterID,
+	)
+	call.ResourceID = networkDomainID
+	call.End(err)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = apiClient.WaitForDeploy(compute.ResourceTypeNetworkDomain, networkDomainID, 5*time.Minute)
+	if err != nil {
+		return "", err
+	}
+
+	logger.Printf("Created network domain '%s' (Id = '%s').", networkDomainName, networkDomainID)
+
+	return networkDomainID, nil
+}
+
+// Recreate VLANs with their original IPv4 address spaces.
+//
+// Returns a map of original VLAN Ids to new VLAN Ids.
+func restoreVLANs(apiClient *compute.Client, networkDomainID string, vlans []inventoryVLAN) (map[string]string, error) {
+	vlanIDs := make(map[string]string)
+	for _, vlan := range vlans {
+		logger.Printf("Creating VLAN '%s' (%s/%d)...",
+			vlan.Name,
+			vlan.IPv4BaseAddress,
+			vlan.IPv4PrefixSize,
+		)
+
+		call := beginAPICall("deploy", "vlan", "", vlan.Name)
+		vlanID, err := apiClient.DeployVLAN(networkDomainID, vlan.Name, vlan.Description, vlan.IPv4BaseAddress, vlan.IPv4PrefixSize)
+		call.ResourceID = vlanID
+		call.End(err)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = apiClient.WaitForDeploy(compute.ResourceTypeVLAN, vlanID, 5*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+
+		vlanIDs[vlan.ID] = vlanID
+
+		logger.Printf("Created VLAN '%s' (Id = '%s').", vlan.Name, vlanID)
+	}
+
+	return vlanIDs, nil
+}
+
+func restorePublicIPBlocks(apiClient *compute.Client, networkDomainID string, count int) error {
+	for index := 0; index < count; index++ {
+		logger.Printf("Adding public IP block %d of %d...", index+1, count)
+
+		call := beginAPICall("add", "publicIPBlock", "", "")
+		publicIPBlockID, err := apiClient.AddPublicIPBlock(networkDomainID)
+		call.ResourceID = publicIPBlockID
+		call.End(err)
+		if err != nil {
+			return err
+		}
+
+		_, err = apiClient.WaitForDeploy(compute.ResourceTypePublicIPBlock, publicIPBlockID, 5*time.Minute)
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Added public IP block '%s'.", publicIPBlockID)
+	}
+
+	return nil
+}
+
+// Recreate NAT rules (the platform assigns new external IP addresses).
+//
+// Returns a map of original external IP addresses to new external IP addresses.
+func restoreNATRules(apiClient *compute.Client, networkDomainID string, natRules []inventoryNATRule) (map[string]string, error) {
+	externalIPAddresses := make(map[string]string)
+	for _, natRule := range natRules {
+		logger.Printf("Creating NAT rule for '%s' (was %s -> %s)...",
+			natRule.InternalIPAddress,
+			natRule.ExternalIPAddress,
+			natRule.InternalIPAddress,
+		)
+
+		call := beginAPICall("add", "natRule", "", "")
+		natRuleID, err := apiClient.AddNATRule(networkDomainID, natRule.InternalIPAddress, nil)
+		call.ResourceID = natRuleID
+		call.End(err)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = apiClient.WaitForDeploy(compute.ResourceTypeNATRule, natRuleID, 5*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+
+		newNATRule, err := apiClient.GetNATRule(natRuleID)
+		if err != nil {
+			return nil, err
+		}
+		if newNATRule == nil {
+			return nil, fmt.Errorf("Unable to find newly-created NAT rule '%s'.", natRuleID)
+		}
+
+		externalIPAddresses[natRule.ExternalIPAddress] = newNATRule.ExternalIPAddress
+
+		logger.Printf("Created NAT rule '%s' (%s -> %s).",
+			natRuleID,
+			newNATRule.ExternalIPAddress,
+			newNATRule.InternalIPAddress,
+		)
+	}
+
+	return externalIPAddresses, nil
+}
+
+// Recreate user-defined firewall rules (in their original order).
+//
+// References to the original external IP addresses are updated to the newly-assigned addresses.
+// Rules that refer to IP address lists or port lists are skipped, since those lists no longer exist.
+func restoreFirewallRules(apiClient *compute.Client, networkDomainID string, firewallRules []inventoryFirewallRule, externalIPAddresses map[string]string) error {
+	for _, firewallRule := range selectFirewallRulesToRestore(firewallRules) {
+		logger.Printf("Creating firewall rule '%s'...", firewallRule.Name)
+
+		configuration := newFirewallRuleConfiguration(firewallRule, networkDomainID, externalIPAddresses)
+		call := beginAPICall("create", "firewallRule", "", firewallRule.Name)
+		firewallRuleID, err := apiClient.CreateFirewallRule(configuration)
+		call.ResourceID = firewallRuleID
+		call.End(err)
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Created firewall rule '%s' (Id = '%s').", firewallRule.Name, firewallRuleID)
+	}
+
+	return nil
+}
+
+// Select the firewall rules that can be recreated (user-defined rules that do not refer to IP address lists or port lists).
+func selectFirewallRulesToRestore(firewallRules []inventoryFirewallRule) []inventoryFirewallRule {
+	var selected []inventoryFirewallRule
+	for _, firewallRule := range firewallRules {
+		if firewallRule.RuleType != "CLIENT_RULE" {
+			continue // Default rules are created by the platform.
+		}
+
+		if usesFirewallLists(firewallRule.Source) || usesFirewallLists(firewallRule.Destination) {
+			logger.Printf("Not recreating firewall rule '%s' because it refers to an IP address list or port list.",
+				firewallRule.Name,
+			)
+
+			continue
+		}
+
+		selected = append(selected, firewallRule)
+	}
+
+	return selected
+}
+
+// Create the configuration for recreating a firewall rule (at the end of the network domain's rule list).
+func newFirewallRuleConfiguration(firewallRule inventoryFirewallRule, networkDomainID string, externalIPAddresses map[string]string) compute.FirewallRuleConfiguration {
+	return compute.FirewallRuleConfiguration{
+		Name:      firewallRule.Name,
+		Action:    firewallRule.Action,
+		Enabled:   firewallRule.Enabled,
+		IPVersion: firewallRule.IPVersion,
+		Protocol:  firewallRule.Protocol,
+		Placement: compute.FirewallRulePlacement{
+			Position: "LAST",
+		},
+		Source:          newFirewallRuleScope(firewallRule.Source, externalIPAddresses),
+		Destination:     newFirewallRuleScope(firewallRule.Destination, externalIPAddresses),
+		NetworkDomainID: networkDomainID,
+	}
+}
+
+func usesFirewallLists(scope inventoryFirewallScope) bool {
+	return scope.AddressListID != "" || scope.PortListID != ""
+}
+
+func newFirewallRuleScope(scope inventoryFirewallScope, externalIPAddresses map[string]string) compute.FirewallRuleScope {
+	var ruleScope compute.FirewallRuleScope
+
+	if scope.IPAddress != "" {
+		ipAddress := scope.IPAddress
+		if newIPAddress, ok := externalIPAddresses[ipAddress]; ok {
+			ipAddress = newIPAddress
+		}
+
+		ruleScope.IPAddress = &compute.FirewallRuleIPAddress{
+			Address:    ipAddress,
+			PrefixSize: scope.PrefixSize,
+		}
+	}
+
+	if scope.PortBegin != nil {
+		ruleScope.Port = &compute.FirewallRulePort{
+			Begin: *scope.PortBegin,
+			End:   scope.PortEnd,
+		}
+	}
+
+	return ruleScope
+}
+
+// Recreate servers as (stopped) placeholders, with their original CPU, memory, and network configuration.
+//
+// Servers whose source images are no longer available are skipped.
+func restoreServers(apiClient *compute.Client, networkDomainID string, servers []inventoryServer, vlanIDs map[string]string, administratorPassword string) error {
+	for _, server := range servers {
+		imageAvailable, err := isImageAvailable(apiClient, server.SourceImageID)
+		if err != nil {
+			return err
+		}
+		if !imageAvailable {
+			logger.Printf("Not recreating server '%s' because its source image ('%s') is no longer available.",
+				server.Name,
+				server.SourceImageID,
+			)
+
+			continue
+		}
+
+		logger.Printf("Creating placeholder for server '%s'...", server.Name)
+
+		configuration := newServerDeploymentConfiguration(server, networkDomainID, vlanIDs, administratorPassword)
+
+		call := beginAPICall("deploy", "server", "", configuration.Name)
+		serverID, err := apiClient.DeployServer(configuration)
+		call.ResourceID = serverID
+		call.End(err)
+		if err != nil {
+			return err
+		}
+
+		_, err = apiClient.WaitForDeploy(compute.ResourceTypeServer, serverID, 20*time.Minute)
+		if err != nil {
+			return err
+		}
+
+		logger.Printf("Created placeholder for server '%s' (Id = '%s').", server.Name, serverID)
+	}
+
+	return nil
+}
+
+// Create the configuration for deploying a (stopped) placeholder for a server, attached to the recreated VLANs.
+func newServerDeploymentConfiguration(server inventoryServer, networkDomainID string, vlanIDs map[string]string, administratorPassword string) compute.ServerDeploymentConfiguration {
+	configuration := compute.ServerDeploymentConfiguration{
+		Name:                  server.Name,
+		Description:           fmt.Sprintf("Placeholder for server '%s' (restored by nifo).", server.ID),
+		ImageID:               server.SourceImageID,
+		AdministratorPassword: administratorPassword,
+		CPU: compute.VirtualMachineCPU{
+			Count:          server.CPUCount,
+			Speed:          server.CPUSpeed,
+			CoresPerSocket: server.CPUCoresPerSocket,
+		},
+		MemoryGB: server.MemoryGB,
+		Network: compute.VirtualMachineNetwork{
+			NetworkDomainID: networkDomainID,
+		},
+		Start: false,
+	}
+	for _, networkAdapter := range server.NetworkAdapters {
+		adapter := compute.VirtualMachineNetworkAdapter{}
+		if vlanID, ok := vlanIDs[networkAdapter.VLANID]; ok {
+			adapter.VLANID = &vlanID
+		}
+		if networkAdapter.PrivateIPv4Address != "" {
+			privateIPv4Address := networkAdapter.PrivateIPv4Address
+			adapter.PrivateIPv4Address = &privateIPv4Address
+		}
+
+		if networkAdapter.Primary {
+			configuration.Network.PrimaryAdapter = adapter
+		} else {
+			configuration.Network.AdditionalNetworkAdapters = append(configuration.Network.AdditionalNetworkAdapters, adapter)
+		}
+	}
+
+	return configuration
+}
+
+// Determine whether the specified OS or customer image still exists.
+func isImageAvailable(apiClient *compute.Client, imageID string) (bool, error) {
+	log.Printf("Checking availability of image '%s'...", imageID)
+
+	osImage, err := apiClient.GetOSImage(imageID)
+	if err != nil {
+		return false, err
+	}
+	if osImage != nil {
+		return true, nil
+	}
+
+	customerImage, err := apiClient.GetCustomerImage(imageID)
+	if err != nil {
+		return false, err
+	}
+
+	return customerImage != nil, nil
+}
@@ -0,0 +1,133 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestNewServerDeploymentConfiguration(t *testing.T) {
+	server := inventoryServer{
+		ID:                "server1",
+		Name:              "web-1",
+		SourceImageID:     "image1",
+		CPUCount:          4,
+		CPUSpeed:          "HIGHPERFORMANCE",
+		CPUCoresPerSocket: 2,
+		MemoryGB:          16,
+		Started:           true,
+		NetworkAdapters: []inventoryNetworkAdapter{
+			{ID: "nic2", Primary: false, VLANID: "old-vlan2", PrivateIPv4Address: "10.0.1.10"},
+			{ID: "nic1", Primary: true, VLANID: "old-vlan1", PrivateIPv4Address: "10.0.0.10"},
+			{ID: "nic3", Primary: false, VLANID: "missing-vlan"},
+		},
+	}
+	vlanIDs := map[string]string{
+		"old-vlan1": "new-vlan1",
+		"old-vlan2": "new-vlan2",
+	}
+
+	configuration := newServerDeploymentConfiguration(server, "new-domain", vlanIDs, "P@ssw0rd")
+	if configuration.Name != "web-1" || configuration.ImageID != "image1" || configuration.AdministratorPassword != "P@ssw0rd" {
+		t.Errorf("Unexpected server identity: %+v", configuration)
+	}
+	if configuration.CPU.Count != 4 || configuration.CPU.Speed != "HIGHPERFORMANCE" || configuration.CPU.CoresPerSocket != 2 || configuration.MemoryGB != 16 {
+		t.Errorf("Unexpected CPU / memory configuration: %+v / %d GB", configuration.CPU, configuration.MemoryGB)
+	}
+	if configuration.Start {
+		t.Error("Expected the placeholder server not to be started")
+	}
+	if configuration.Network.NetworkDomainID != "new-domain" {
+		t.Errorf("Expected network domain 'new-domain', but got '%s'", configuration.Network.NetworkDomainID)
+	}
+
+	primary := configuration.Network.PrimaryAdapter
+	if primary.VLANID == nil || *primary.VLANID != "new-vlan1" || primary.PrivateIPv4Address == nil || *primary.PrivateIPv4Address != "10.0.0.10" {
+		t.Errorf("Expected primary adapter on VLAN 'new-vlan1' with address 10.0.0.10, but got %+v", primary)
+	}
+
+	additional := configuration.Network.AdditionalNetworkAdapters
+	if len(additional) != 2 {
+		t.Fatalf("Expected 2 additional network adapters, but got %d", len(additional))
+	}
+	if additional[0].VLANID == nil || *additional[0].VLANID != "new-vlan2" || *additional[0].PrivateIPv4Address != "10.0.1.10" {
+		t.Errorf("Expected additional adapter on VLAN 'new-vlan2' with address 10.0.1.10, but got %+v", additional[0])
+	}
+	if additional[1].VLANID != nil || additional[1].PrivateIPv4Address != nil {
+		t.Errorf("Expected adapter for a VLAN that was not recreated to have no VLAN or address, but got %+v", additional[1])
+	}
+}
+
+func TestSelectFirewallRulesToRestore(t *testing.T) {
+	firewallRules := []inventoryFirewallRule{
+		{Name: "CCDEFAULT.BlockOutboundMailIPv4", RuleType: "DEFAULT_RULE"},
+		{Name: "Allow_HTTPS", RuleType: "CLIENT_RULE"},
+		{Name: "Allow_Office", RuleType: "CLIENT_RULE", Source: inventoryFirewallScope{AddressListID: "list1"}},
+		{Name: "Allow_Web_Ports", RuleType: "CLIENT_RULE", Destination: inventoryFirewallScope{PortListID: "list2"}},
+		{Name: "Allow_SSH", RuleType: "CLIENT_RULE"},
+	}
+
+	selected := selectFirewallRulesToRestore(firewallRules)
+	if len(selected) != 2 || selected[0].Name != "Allow_HTTPS" || selected[1].Name != "Allow_SSH" {
+		t.Errorf("Expected only 'Allow_HTTPS' and 'Allow_SSH' (in order) to be restored, but got %+v", selected)
+	}
+}
+
+func TestNewFirewallRuleConfiguration(t *testing.T) {
+	prefixSize := 24
+	portBegin := 8000
+	portEnd := 8080
+	firewallRule := inventoryFirewallRule{
+		Name:        "Allow_Web",
+		RuleType:    "CLIENT_RULE",
+		Action:      "ACCEPT_DECISIVELY",
+		IPVersion:   "IPV4",
+		Protocol:    "TCP",
+		Enabled:     true,
+		Source:      inventoryFirewallScope{IPAddress: "203.0.113.0", PrefixSize: &prefixSize},
+		Destination: inventoryFirewallScope{IPAddress: "168.128.1.10", PortBegin: &portBegin, PortEnd: &portEnd},
+	}
+	externalIPAddresses := map[string]string{
+		"168.128.1.10": "168.128.9.20", // The NAT rule's old public IP -> its new public IP.
+	}
+
+	configuration := newFirewallRuleConfiguration(firewallRule, "new-domain", externalIPAddresses)
+	if configuration.Name != "Allow_Web" || configuration.Action != "ACCEPT_DECISIVELY" || configuration.IPVersion != "IPV4" || configuration.Protocol != "TCP" || !configuration.Enabled {
+		t.Errorf("Unexpected firewall rule configuration: %+v", configuration)
+	}
+	if configuration.NetworkDomainID != "new-domain" || configuration.Placement.Position != "LAST" {
+		t.Errorf("Expected rule to be placed last in 'new-domain', but got %+v", configuration)
+	}
+
+	source := configuration.Source
+	if source.IPAddress == nil || source.IPAddress.Address != "203.0.113.0" || source.IPAddress.PrefixSize == nil || *source.IPAddress.PrefixSize != 24 || source.Port != nil {
+		t.Errorf("Expected unchanged source 203.0.113.0/24 (any port), but got %+v", source)
+	}
+
+	destination := configuration.Destination
+	if destination.IPAddress == nil || destination.IPAddress.Address != "168.128.9.20" {
+		t.Errorf("Expected destination to be remapped to the new public IP 168.128.9.20, but got %+v", destination.IPAddress)
+	}
+	if destination.Port == nil || destination.Port.Begin != 8000 || destination.Port.End == nil || *destination.Port.End != 8080 {
+		t.Errorf("Expected destination ports 8000-8080, but got %+v", destination.Port)
+	}
+
+	anyScope := newFirewallRuleScope(inventoryFirewallScope{}, externalIPAddresses)
+	if anyScope.IPAddress != nil || anyScope.Port != nil {
+		t.Errorf("Expected an empty scope to match any address and port, but got %+v", anyScope)
+	}
+}
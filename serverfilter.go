@@ -0,0 +1,194 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// serverFilter determines which servers in a network domain will be destroyed.
+//
+// A server is selected if it matches at least one of the names, tags, and VLANs (for each of those that are specified),
+// and does not match any of the exclusions.
+type serverFilter struct {
+	Names        []string
+	Tags         []serverTagSelector
+	VLANs        []string
+	ExcludeNames []string
+	ExcludeTags  []serverTagSelector
+	ExcludeVLANs []string
+}
+
+// serverTagSelector matches servers that have a tag with the specified name and value.
+type serverTagSelector struct {
+	Name  string
+	Value string
+}
+
+// Parse a server tag selector of the form "key=value".
+func parseServerTagSelector(selector string) (serverTagSelector, error) {
+	separatorIndex := strings.Index(selector, "=")
+	if separatorIndex < 1 {
+		return serverTagSelector{}, fmt.Errorf("Invalid server tag selector '%s' (must be of the form key=value).", selector)
+	}
+
+	return serverTagSelector{
+		Name:  selector[:separatorIndex],
+		Value: selector[separatorIndex+1:],
+	}, nil
+}
+
+// Parse server tag selectors of the form "key=value".
+func parseServerTagSelectors(selectors []string) ([]serverTagSelector, error) {
+	var parsed []serverTagSelector
+	for _, selector := range selectors {
+		tagSelector, err := parseServerTagSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+
+		parsed = append(parsed, tagSelector)
+	}
+
+	return parsed, nil
+}
+
+// IsEmpty determines whether the filter selects all servers.
+func (filter serverFilter) IsEmpty() bool {
+	return len(filter.Names) == 0 &&
+		len(filter.Tags) == 0 &&
+		len(filter.VLANs) == 0 &&
+		len(filter.ExcludeNames) == 0 &&
+		len(filter.ExcludeTags) == 0 &&
+		len(filter.ExcludeVLANs) == 0
+}
+
+// Validate the server filter's name patterns.
+func (filter serverFilter) Validate() error {
+	for _, pattern := range append(filter.Names, filter.ExcludeNames...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("Invalid server name pattern '%s'.", pattern)
+		}
+	}
+
+	return nil
+}
+
+// Apply the filter to the specified servers.
+func (filter serverFilter) Apply(apiClient *compute.Client, servers []compute.Server) ([]compute.Server, error) {
+	if filter.IsEmpty() {
+		return servers, nil
+	}
+
+	var selected []compute.Server
+	for _, server := range servers {
+		var tags []compute.TagDetail
+		if len(filter.Tags) > 0 || len(filter.ExcludeTags) > 0 {
+			var err error
+			tags, err = listAssetTags(apiClient, server.ID, compute.AssetTypeServer)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !filter.matches(server, tags) {
+			log.Printf("Server '%s' ('%s') does not match the server filter; it will not be destroyed.",
+				server.Name,
+				server.ID,
+			)
+
+			continue
+		}
+
+		selected = append(selected, server)
+	}
+
+	return selected, nil
+}
+
+func (filter serverFilter) matches(server compute.Server, tags []compute.TagDetail) bool {
+	if len(filter.Names) > 0 && !matchesServerName(server, filter.Names) {
+		return false
+	}
+	if len(filter.Tags) > 0 && !matchesServerTag(tags, filter.Tags) {
+		return false
+	}
+	if len(filter.VLANs) > 0 && !matchesServerVLAN(server, filter.VLANs) {
+		return false
+	}
+
+	if matchesServerName(server, filter.ExcludeNames) {
+		return false
+	}
+	if matchesServerTag(tags, filter.ExcludeTags) {
+		return false
+	}
+	if matchesServerVLAN(server, filter.ExcludeVLANs) {
+		return false
+	}
+
+	return true
+}
+
+func matchesServerName(server compute.Server, patterns []string) bool {
+	for _, pattern := range patterns {
+		matched, _ := path.Match(pattern, server.Name)
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesServerTag(tags []compute.TagDetail, selectors []serverTagSelector) bool {
+	for _, selector := range selectors {
+		for _, tag := range tags {
+			if tag.Name == selector.Name && tag.Value == selector.Value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Match a server against VLAN names (or Ids) on any of its network adapters.
+func matchesServerVLAN(server compute.Server, vlans []string) bool {
+	adapters := append(
+		[]compute.VirtualMachineNetworkAdapter{server.Network.PrimaryAdapter},
+		server.Network.AdditionalNetworkAdapters...,
+	)
+	for _, vlan := range vlans {
+		for _, adapter := range adapters {
+			if adapter.VLANName != nil && *adapter.VLANName == vlan {
+				return true
+			}
+			if adapter.VLANID != nil && *adapter.VLANID == vlan {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,186 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestServerFilterMatches(t *testing.T) {
+	web1 := testServer("web-1", "vlan-front", "Front end")
+	db1 := testServer("db-1", "vlan-back", "Back end")
+	db1.Network.AdditionalNetworkAdapters = []compute.VirtualMachineNetworkAdapter{testNetworkAdapter("vlan-backup", "Backup")}
+
+	tags := map[string][]compute.TagDetail{
+		"web-1": {{Name: "role", Value: "web"}, {Name: "keep", Value: "true"}},
+		"db-1":  {{Name: "role", Value: "db"}},
+	}
+
+	testCases := []struct {
+		Name     string
+		Filter   serverFilter
+		Expected []string
+	}{
+		{
+			Name:     "empty filter",
+			Filter:   serverFilter{},
+			Expected: []string{"web-1", "db-1"},
+		},
+		{
+			Name:     "name pattern",
+			Filter:   serverFilter{Names: []string{"web-*"}},
+			Expected: []string{"web-1"},
+		},
+		{
+			Name:     "any of several name patterns",
+			Filter:   serverFilter{Names: []string{"web-*", "db-?"}},
+			Expected: []string{"web-1", "db-1"},
+		},
+		{
+			Name:     "tag",
+			Filter:   serverFilter{Tags: []serverTagSelector{{Name: "role", Value: "db"}}},
+			Expected: []string{"db-1"},
+		},
+		{
+			Name:     "tag value must match",
+			Filter:   serverFilter{Tags: []serverTagSelector{{Name: "role", Value: "cache"}}},
+			Expected: nil,
+		},
+		{
+			Name:     "VLAN name",
+			Filter:   serverFilter{VLANs: []string{"Front end"}},
+			Expected: []string{"web-1"},
+		},
+		{
+			Name:     "VLAN Id on an additional network adapter",
+			Filter:   serverFilter{VLANs: []string{"vlan-backup"}},
+			Expected: []string{"db-1"},
+		},
+		{
+			Name:     "name and tag must both match",
+			Filter:   serverFilter{Names: []string{"web-*"}, Tags: []serverTagSelector{{Name: "role", Value: "db"}}},
+			Expected: nil,
+		},
+		{
+			Name:     "excluded name takes precedence over included name",
+			Filter:   serverFilter{Names: []string{"*"}, ExcludeNames: []string{"db-*"}},
+			Expected: []string{"web-1"},
+		},
+		{
+			Name:     "excluded tag takes precedence over included VLAN",
+			Filter:   serverFilter{VLANs: []string{"Front end", "Back end"}, ExcludeTags: []serverTagSelector{{Name: "keep", Value: "true"}}},
+			Expected: []string{"db-1"},
+		},
+		{
+			Name:     "excluded VLAN takes precedence over included tag",
+			Filter:   serverFilter{Tags: []serverTagSelector{{Name: "role", Value: "db"}}, ExcludeVLANs: []string{"Backup"}},
+			Expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		var actual []string
+		for _, server := range []compute.Server{web1, db1} {
+			if testCase.Filter.matches(server, tags[server.Name]) {
+				actual = append(actual, server.Name)
+			}
+		}
+
+		if len(actual) != len(testCase.Expected) {
+			t.Errorf("%s: expected %v, but got %v", testCase.Name, testCase.Expected, actual)
+
+			continue
+		}
+		for index := range actual {
+			if actual[index] != testCase.Expected[index] {
+				t.Errorf("%s: expected %v, but got %v", testCase.Name, testCase.Expected, actual)
+
+				break
+			}
+		}
+	}
+}
+
+func TestParseServerTagSelector(t *testing.T) {
+	testCases := []struct {
+		Selector string
+		Expected serverTagSelector
+		Error    bool
+	}{
+		{Selector: "role=web", Expected: serverTagSelector{Name: "role", Value: "web"}},
+		{Selector: "role=", Expected: serverTagSelector{Name: "role", Value: ""}},
+		{Selector: "query=a=b", Expected: serverTagSelector{Name: "query", Value: "a=b"}},
+		{Selector: "role", Error: true},
+		{Selector: "=web", Error: true},
+		{Selector: "", Error: true},
+	}
+
+	for _, testCase := range testCases {
+		actual, err := parseServerTagSelector(testCase.Selector)
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("'%s': expected an error, but got %+v", testCase.Selector, actual)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("'%s': unexpected error: %s", testCase.Selector, err)
+
+			continue
+		}
+		if actual != testCase.Expected {
+			t.Errorf("'%s': expected %+v, but got %+v", testCase.Selector, testCase.Expected, actual)
+		}
+	}
+
+	_, err := programOptions{ServerTag: []string{"role=web"}, ExcludeServerTag: []string{"keep"}}.ServerFilter()
+	if err == nil {
+		t.Error("Expected a malformed --exclude-server-tag to be rejected")
+	}
+}
+
+func TestServerFilterValidate(t *testing.T) {
+	err := serverFilter{Names: []string{"web-*"}, ExcludeNames: []string{"web-[0-9]"}}.Validate()
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+
+	err = serverFilter{ExcludeNames: []string{"web-["}}.Validate()
+	if err == nil {
+		t.Error("Expected an invalid server name pattern to be rejected")
+	}
+}
+
+func testServer(name string, vlanID string, vlanName string) compute.Server {
+	server := compute.Server{
+		ID:   name + "-id",
+		Name: name,
+	}
+	server.Network.PrimaryAdapter = testNetworkAdapter(vlanID, vlanName)
+
+	return server
+}
+
+func testNetworkAdapter(vlanID string, vlanName string) compute.VirtualMachineNetworkAdapter {
+	return compute.VirtualMachineNetworkAdapter{
+		VLANID:   &vlanID,
+		VLANName: &vlanName,
+	}
+}
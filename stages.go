@@ -0,0 +1,338 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// A nukeStage is a single step in the destruction of a network domain.
+type nukeStage struct {
+	// The stage name (as used with --only and --skip).
+	Name string
+
+	// A description of the resources that the stage destroys.
+	Description string
+
+	// The names of the stages whose resources must be gone before this stage can run.
+	DependsOn []string
+
+	// Count the resources remaining for the stage to destroy.
+	Count func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error)
+
+	// Describe the resources that the stage will destroy, one per line, for display in the plan (optional).
+	Details func(apiClient *compute.Client, networkDomainID string, options programOptions) ([]string, error)
+
+	// Destroy the stage's resources.
+	Nuke func(apiClient *compute.Client, networkDomainID string, options programOptions) error
+}
+
+// All stages, in the order that they are run.
+var allStages = []nukeStage{
+	{
+		Name:        "virtuallisteners",
+		Description: "virtual listeners",
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			virtualListeners, err := listVirtualListeners(apiClient, networkDomainID)
+
+			return len(virtualListeners), err
+		},
+		Nuke: nukeVirtualListeners,
+	},
+	{
+		Name:        "ssloffloadprofiles",
+		Description: "SSL offload profiles",
+		DependsOn:   []string{"virtuallisteners"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			sslOffloadProfiles, err := listSSLOffloadProfiles(apiClient, networkDomainID)
+
+			return len(sslOffloadProfiles), err
+		},
+		Nuke: nukeSSLOffloadProfiles,
+	},
+	{
+		Name:        "vippools",
+		Description: "VIP pools",
+		DependsOn:   []string{"virtuallisteners"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			vipPools, err := listVIPPools(apiClient, networkDomainID)
+
+			return len(vipPools), err
+		},
+		Nuke: nukeVIPPools,
+	},
+	{
+		Name:        "vipnodes",
+		Description: "VIP nodes",
+		DependsOn:   []string{"vippools"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			vipNodes, err := listVIPNodes(apiClient, networkDomainID)
+
+			return len(vipNodes), err
+		},
+		Nuke: nukeVIPNodes,
+	},
+	{
+		Name:        "sslcertificates",
+		Description: "SSL domain certificates and certificate chains",
+		DependsOn:   []string{"ssloffloadprofiles"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			return countSSLCertificates(apiClient, networkDomainID)
+		},
+		Nuke: nukeSSLCertificates,
+	},
+	{
+		Name:        "natrules",
+		Description: "NAT rules",
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			natRules, err := listNATRules(apiClient, networkDomainID)
+
+			return len(natRules), err
+		},
+		Nuke: nukeNATRules,
+	},
+	{
+		Name:        "publicips",
+		Description: "public IP blocks",
+		DependsOn:   []string{"natrules", "virtuallisteners"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			publicIPBlocks, err := listPublicIPBlocks(apiClient, networkDomainID)
+
+			return len(publicIPBlocks), err
+		},
+		Nuke: nukePublicIPBlocks,
+	},
+	{
+		Name:        "antiaffinity",
+		Description: "server anti-affinity rules",
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			antiAffinityRules, err := selectServerAntiAffinityRules(apiClient, networkDomainID, options)
+
+			return len(antiAffinityRules), err
+		},
+		Details: func(apiClient *compute.Client, networkDomainID string, options programOptions) ([]string, error) {
+			antiAffinityRules, err := selectServerAntiAffinityRules(apiClient, networkDomainID, options)
+			if err != nil {
+				return nil, err
+			}
+
+			details := make([]string, len(antiAffinityRules))
+			for index, antiAffinityRule := range antiAffinityRules {
+				details[index] = fmt.Sprintf("rule '%s': %s", antiAffinityRule.ID, describeAntiAffinityServers(antiAffinityRule))
+			}
+
+			return details, nil
+		},
+		Nuke: nukeServerAntiAffinityRules,
+	},
+	{
+		Name:        "servers",
+		Description: "servers",
+		DependsOn:   []string{"antiaffinity"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			servers, err := selectServers(apiClient, networkDomainID, options)
+
+			return len(servers), err
+		},
+		Nuke: nukeServers,
+	},
+	{
+		Name:        "staticroutes",
+		Description: "user-defined static routes",
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			staticRoutes, err := listUserStaticRoutes(apiClient, networkDomainID)
+
+			return len(staticRoutes), err
+		},
+		Nuke: nukeStaticRoutes,
+	},
+	{
+		Name:        "reservedips",
+		Description: "reserved private IPv4 / IPv6 addresses",
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			return countReservedIPAddresses(apiClient, networkDomainID)
+		},
+		Nuke: nukeReservedIPAddresses,
+	},
+	{
+		Name:        "vlans",
+		Description: "VLANs",
+		DependsOn:   []string{"servers", "staticroutes", "reservedips"},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			vlans, err := listVLANs(apiClient, networkDomainID)
+
+			return len(vlans), err
+		},
+		Nuke: nukeVLANs,
+	},
+	{
+		Name:        "networkdomain",
+		Description: "the network domain itself",
+		DependsOn: []string{
+			"virtuallisteners", "ssloffloadprofiles", "vippools", "vipnodes", "sslcertificates",
+			"natrules", "publicips", "antiaffinity", "servers", "staticroutes", "reservedips", "vlans",
+		},
+		Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+			return 1, nil
+		},
+		Nuke: nukeNetworkDomain,
+	},
+}
+
+// The stages that only act on the selected servers (when some servers are being selected using --server-xxx).
+var serverScopedStageNames = []string{"antiaffinity", "servers"}
+
+// The stages that cannot run unless every server in the network domain is being destroyed.
+var emptyDomainStageNames = []string{"staticroutes", "reservedips", "vlans", "networkdomain"}
+
+// Find the stage with the specified name.
+func findStage(name string) *nukeStage {
+	for index := range allStages {
+		if allStages[index].Name == name {
+			return &allStages[index]
+		}
+	}
+
+	return nil
+}
+
+// Select the stages to run, based on the --only and --skip options.
+//
+// Stages are always returned in the order they are run, regardless of the order in which they were specified.
+func selectStages(only []string, skip []string) ([]nukeStage, error) {
+	for _, name := range append(only, skip...) {
+		if findStage(name) == nil {
+			return nil, fmt.Errorf("Unknown stage '%s' (valid stages are: %s).", name, stageNames(allStages))
+		}
+	}
+
+	var selected []nukeStage
+	for _, stage := range allStages {
+		if len(only) > 0 && !containsString(only, stage.Name) {
+			continue
+		}
+		if containsString(skip, stage.Name) {
+			continue
+		}
+
+		selected = append(selected, stage)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("No stages selected.")
+	}
+
+	return selected, nil
+}
+
+// Ensure that, for each selected stage, any stage it depends on has either been selected or has nothing left to destroy.
+//
+// This catches (for example) an attempt to delete VLANs while servers remain, before anything has been deleted.
+func checkStageDependencies(apiClient *compute.Client, networkDomainID string, stages []nukeStage, options programOptions) error {
+	return checkStageDependenciesUsing(networkDomainID, stages, func(dependency *nukeStage) (int, error) {
+		return dependency.Count(apiClient, networkDomainID, options)
+	})
+}
+
+// Ensure that each selected stage's dependencies have been selected or have nothing left to destroy (as determined by countRemaining).
+func checkStageDependenciesUsing(networkDomainID string, stages []nukeStage, countRemaining func(dependency *nukeStage) (int, error)) error {
+	selectedNames := make([]string, len(stages))
+	for index, stage := range stages {
+		selectedNames[index] = stage.Name
+	}
+
+	checked := make(map[string]bool)
+	for _, stage := range stages {
+		for _, dependencyName := range stage.DependsOn {
+			if containsString(selectedNames, dependencyName) || checked[dependencyName] {
+				continue
+			}
+
+			dependency := findStage(dependencyName)
+			remaining, err := countRemaining(dependency)
+			if err != nil {
+				return err
+			}
+			if remaining > 0 {
+				return &protectionError{
+					Message: fmt.Sprintf("Cannot run stage '%s' because network domain '%s' still contains %d %s (add stage '%s' to remove them).",
+						stage.Name,
+						networkDomainID,
+						remaining,
+						dependency.Description,
+						dependency.Name,
+					),
+				}
+			}
+
+			checked[dependencyName] = true
+		}
+	}
+
+	return nil
+}
+
+// Get a comma-separated list of stage names.
+func stageNames(stages []nukeStage) string {
+	names := make([]string, len(stages))
+	for index, stage := range stages {
+		names[index] = stage.Name
+	}
+
+	return strings.Join(names, ",")
+}
+
+// Determine whether the stage with the specified name is one of the specified stages.
+func hasStage(stages []nukeStage, name string) bool {
+	for _, stage := range stages {
+		if stage.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Determine whether the specified stages cover the entire network domain (including the domain itself).
+func isFullNuke(stages []nukeStage) bool {
+	return len(stages) == len(allStages)
+}
+
+// Split a comma-separated list, ignoring empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,165 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSelectStages(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Only     []string
+		Skip     []string
+		Expected string
+		Error    bool
+	}{
+		{
+			Name:     "all stages",
+			Expected: stageNames(allStages),
+		},
+		{
+			Name:     "only (in run order, regardless of the order specified)",
+			Only:     []string{"vlans", "servers", "natrules"},
+			Expected: "natrules,servers,vlans",
+		},
+		{
+			Name:     "skip",
+			Skip:     []string{"networkdomain", "vlans"},
+			Expected: "virtuallisteners,ssloffloadprofiles,vippools,vipnodes,sslcertificates,natrules,publicips,antiaffinity,servers,staticroutes,reservedips",
+		},
+		{
+			Name:     "only and skip",
+			Only:     []string{"natrules", "publicips"},
+			Skip:     []string{"publicips"},
+			Expected: "natrules",
+		},
+		{
+			Name:  "unknown stage in only",
+			Only:  []string{"servers", "routers"},
+			Error: true,
+		},
+		{
+			Name:  "unknown stage in skip",
+			Skip:  []string{"Servers"},
+			Error: true,
+		},
+		{
+			Name:  "nothing selected",
+			Only:  []string{"servers"},
+			Skip:  []string{"servers"},
+			Error: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		stages, err := selectStages(testCase.Only, testCase.Skip)
+		if testCase.Error {
+			if err == nil {
+				t.Errorf("%s: expected an error, but got stages '%s'", testCase.Name, stageNames(stages))
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+
+			continue
+		}
+		if actual := stageNames(stages); actual != testCase.Expected {
+			t.Errorf("%s: expected stages '%s', but got '%s'", testCase.Name, testCase.Expected, actual)
+		}
+	}
+}
+
+func TestCheckStageDependencies(t *testing.T) {
+	testCases := []struct {
+		Name      string
+		Only      []string
+		Remaining map[string]int
+		Violation bool
+	}{
+		{
+			Name:      "all stages",
+			Remaining: map[string]int{"servers": 3, "vlans": 2},
+		},
+		{
+			Name:      "VLANs while servers remain",
+			Only:      []string{"vlans"},
+			Remaining: map[string]int{"servers": 1},
+			Violation: true,
+		},
+		{
+			Name:      "VLANs while static routes remain",
+			Only:      []string{"servers", "vlans"},
+			Remaining: map[string]int{"staticroutes": 1},
+			Violation: true,
+		},
+		{
+			Name:      "VLANs when servers are already gone",
+			Only:      []string{"vlans"},
+			Remaining: map[string]int{"vlans": 2},
+		},
+		{
+			Name:      "VLANs with servers selected",
+			Only:      []string{"antiaffinity", "servers", "staticroutes", "reservedips", "vlans"},
+			Remaining: map[string]int{"servers": 3},
+		},
+		{
+			Name:      "public IPs while NAT rules remain",
+			Only:      []string{"publicips"},
+			Remaining: map[string]int{"natrules": 1},
+			Violation: true,
+		},
+		{
+			Name:      "stages without dependencies",
+			Only:      []string{"natrules", "antiaffinity"},
+			Remaining: map[string]int{"servers": 1, "vlans": 1},
+		},
+	}
+
+	for _, testCase := range testCases {
+		stages, err := selectStages(testCase.Only, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", testCase.Name, err)
+		}
+
+		err = checkStageDependenciesUsing("domain1", stages, func(dependency *nukeStage) (int, error) {
+			return testCase.Remaining[dependency.Name], nil
+		})
+		if testCase.Violation {
+			if _, ok := err.(*protectionError); !ok {
+				t.Errorf("%s: expected a protection error, but got: %v", testCase.Name, err)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", testCase.Name, err)
+		}
+	}
+
+	countError := fmt.Errorf("CloudControl is unavailable.")
+	stages, _ := selectStages([]string{"vlans"}, nil)
+	err := checkStageDependenciesUsing("domain1", stages, func(dependency *nukeStage) (int, error) {
+		return 0, countError
+	})
+	if err != countError {
+		t.Errorf("Expected the count error to be returned, but got: %v", err)
+	}
+}
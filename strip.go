@@ -0,0 +1,303 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+// stripOptions represents the options for the "strip" command.
+type stripOptions struct {
+	NICs  bool `long:"nics" description:"Remove additional network adapters (if neither --nics nor --disks is specified, both are removed)."`
+	Disks bool `long:"disks" description:"Remove non-primary disks (if neither --nics nor --disks is specified, both are removed)."`
+}
+
+// RemoveNICs determines whether additional network adapters should be removed.
+func (options stripOptions) RemoveNICs() bool {
+	return options.NICs || !options.Disks
+}
+
+// RemoveDisks determines whether non-primary disks should be removed.
+func (options stripOptions) RemoveDisks() bool {
+	return options.Disks || !options.NICs
+}
+
+// A strippableDisk is a non-primary disk that will be removed from a server.
+type strippableDisk struct {
+	compute.VirtualMachineDisk
+
+	// The bus number of the SCSI controller that the disk is attached to.
+	SCSIBusNumber int
+}
+
+// Remove additional network adapters and / or non-primary disks from the selected servers in the target network domain (without deleting the servers).
+//
+// Servers that are running are shut down first, and started again once they have been stripped (or if stripping them fails).
+// Like a nuke, the plan is displayed and confirmed, an inventory is captured first, and the run is reported on, audited, and traced.
+func strip(apiClient *compute.Client, networkDomain *compute.NetworkDomain, options programOptions) error {
+	audit.RecordTarget(networkDomain)
+
+	servers, err := selectServers(apiClient, networkDomain.ID, options)
+	if err != nil {
+		return err
+	}
+
+	var strippableServers []compute.Server
+	resourceCount := 0
+	for _, server := range servers {
+		networkAdapters, disks := strippableResources(server, options.Strip)
+		if len(networkAdapters) == 0 && len(disks) == 0 {
+			continue
+		}
+
+		strippableServers = append(strippableServers, server)
+		resourceCount += len(networkAdapters) + len(disks)
+	}
+
+	stages := []nukeStage{
+		{
+			Name:        "strip",
+			Description: "additional network adapters and non-primary disks",
+			Count: func(apiClient *compute.Client, networkDomainID string, options programOptions) (int, error) {
+				return resourceCount, nil
+			},
+			Details: func(apiClient *compute.Client, networkDomainID string, options programOptions) ([]string, error) {
+				details := make([]string, len(strippableServers))
+				for index, server := range strippableServers {
+					networkAdapters, disks := strippableResources(server, options.Strip)
+					details[index] = fmt.Sprintf("server '%s' ('%s'): %d network adapter(s), %d disk(s)",
+						server.Name,
+						server.ID,
+						len(networkAdapters),
+						len(disks),
+					)
+				}
+
+				return details, nil
+			},
+			Nuke: func(apiClient *compute.Client, networkDomainID string, options programOptions) error {
+				progress.SetStageTotal(resourceCount)
+
+				for _, server := range strippableServers {
+					err := stripServer(apiClient, server, options.Strip)
+					if err != nil {
+						return err
+					}
+				}
+
+				return nil
+			},
+		},
+	}
+
+	err = showPlan(apiClient, networkDomain, stages, options)
+	if err != nil {
+		return err
+	}
+	if resourceCount == 0 {
+		logger.Printf("Nothing to remove from servers in network domain '%s'.", networkDomain.Name)
+
+		return nil
+	}
+
+	err = confirmStages(networkDomain, stages, nil, options)
+	if err != nil {
+		return err
+	}
+
+	inventoryFile, err := captureAndWriteInventory(apiClient, networkDomain, options)
+	if err != nil {
+		return err
+	}
+
+	report := newRunReport(networkDomain.ID, networkDomain.Name)
+	report.InventoryFile = inventoryFile
+
+	logger.Printf("Stripping %d server(s) in network domain '%s'...", len(strippableServers), networkDomain.ID)
+
+	return runStages(apiClient, "strip", networkDomain.ID, stages, report, options)
+}
+
+// Determine which of a server's network adapters and disks will be removed.
+//
+// The primary disk is the one at SCSI unit 0 on the SCSI controller with bus number 0; disks on other controllers are
+// never treated as primary, even if they are also at unit 0.
+func strippableResources(server compute.Server, options stripOptions) (networkAdapters []compute.VirtualMachineNetworkAdapter, disks []strippableDisk) {
+	if options.RemoveNICs() {
+		networkAdapters = server.Network.AdditionalNetworkAdapters
+	}
+
+	if options.RemoveDisks() {
+		for _, controller := range server.SCSIControllers {
+			for _, disk := range controller.Disks {
+				if controller.BusNumber == 0 && disk.SCSIUnitID == 0 {
+					continue // The primary disk.
+				}
+
+				disks = append(disks, strippableDisk{
+					VirtualMachineDisk: disk,
+					SCSIBusNumber:      controller.BusNumber,
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// Strip a server's additional network adapters and / or non-primary disks.
+//
+// If the server is running, it is shut down first and started again afterwards (even if stripping it fails).
+func stripServer(apiClient *compute.Client, server compute.Server, options stripOptions) (err error) {
+	logger.Printf("Stripping server '%s' ('%s')...", server.Name, server.ID)
+
+	if server.Started {
+		err = shutdownServer(apiClient, server)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			startErr := startServer(apiClient, server)
+			if startErr != nil {
+				logger.Println(startErr)
+				if err == nil {
+					err = startErr
+				}
+			}
+		}()
+	}
+
+	networkAdapters, disks := strippableResources(server, options)
+	for _, networkAdapter := range networkAdapters {
+		networkAdapterID := stringOrEmpty(networkAdapter.ID)
+		action := beginAction(logEvent{Stage: "strip", ResourceType: "networkAdapter", ResourceID: networkAdapterID, Action: "remove-nic"},
+			"Removing network adapter '%s' (VLAN '%s') from server '%s'...",
+			networkAdapterID,
+			stringOrEmpty(networkAdapter.VLANName),
+			server.ID,
+		)
+
+		call := action.BeginAPICall()
+		err = apiClient.RemoveNICFromServer(networkAdapterID)
+		call.End(err)
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		_, err = apiClient.WaitForChange(compute.ResourceTypeServer, server.ID, "Remove network adapter", 5*time.Minute)
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Removed network adapter '%s' from server '%s'.", networkAdapterID, server.ID)
+	}
+
+	for _, disk := range disks {
+		diskID := stringOrEmpty(disk.ID)
+		action := beginAction(logEvent{Stage: "strip", ResourceType: "disk", ResourceID: diskID, Action: "remove-disk"},
+			"Removing disk '%s' (SCSI controller %d, unit %d, %dGB, %s) from server '%s'...",
+			diskID,
+			disk.SCSIBusNumber,
+			disk.SCSIUnitID,
+			disk.SizeGB,
+			disk.Speed,
+			server.ID,
+		)
+
+		call := action.BeginAPICall()
+		err = apiClient.RemoveServerDisk(server.ID, diskID)
+		call.End(err)
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		_, err = apiClient.WaitForChange(compute.ResourceTypeServer, server.ID, "Remove disk", 10*time.Minute)
+		if err != nil {
+			action.Fail(err)
+
+			return err
+		}
+
+		action.Complete("Removed disk '%s' from server '%s'.", diskID, server.ID)
+	}
+
+	logger.Printf("Stripped server '%s' ('%s').", server.Name, server.ID)
+
+	return nil
+}
+
+func shutdownServer(apiClient *compute.Client, server compute.Server) error {
+	action := beginAction(logEvent{Stage: "strip", ResourceType: "server", ResourceID: server.ID, ResourceName: server.Name, Action: "shutdown"},
+		"Shutting down server '%s'...", server.ID,
+	)
+
+	call := action.BeginAPICall()
+	err := apiClient.ShutdownServer(server.ID)
+	call.End(err)
+	if err != nil {
+		action.Fail(err)
+
+		return err
+	}
+
+	_, err = apiClient.WaitForChange(compute.ResourceTypeServer, server.ID, "Shut down server", 5*time.Minute)
+	if err != nil {
+		action.Fail(err)
+
+		return err
+	}
+
+	action.Complete("Shut down server '%s'.", server.ID)
+
+	return nil
+}
+
+func startServer(apiClient *compute.Client, server compute.Server) error {
+	action := beginAction(logEvent{Stage: "strip", ResourceType: "server", ResourceID: server.ID, ResourceName: server.Name, Action: "start"},
+		"Starting server '%s'...", server.ID,
+	)
+
+	call := action.BeginAPICall()
+	err := apiClient.StartServer(server.ID)
+	call.End(err)
+	if err != nil {
+		action.Fail(err)
+
+		return err
+	}
+
+	_, err = apiClient.WaitForChange(compute.ResourceTypeServer, server.ID, "Start server", 5*time.Minute)
+	if err != nil {
+		action.Fail(err)
+
+		return err
+	}
+
+	action.Complete("Started server '%s'.", server.ID)
+
+	return nil
+}
@@ -0,0 +1,141 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"github.com/DimensionDataResearch/go-dd-cloud-compute/compute"
+)
+
+func TestStrippableResources(t *testing.T) {
+	server := compute.Server{
+		ID:   "server1",
+		Name: "web-1",
+		SCSIControllers: compute.VirtualMachineSCSIControllers{
+			{
+				BusNumber: 0,
+				Disks: []compute.VirtualMachineDisk{
+					testDisk("os-disk", 0),
+					testDisk("data-disk", 1),
+				},
+			},
+			{
+				BusNumber: 1,
+				Disks: []compute.VirtualMachineDisk{
+					testDisk("second-controller-disk", 0),
+				},
+			},
+		},
+	}
+	server.Network.PrimaryAdapter = testNetworkAdapter("vlan1", "Primary")
+	server.Network.AdditionalNetworkAdapters = []compute.VirtualMachineNetworkAdapter{
+		testNetworkAdapter("vlan2", "Backup"),
+		testNetworkAdapter("vlan3", "Storage"),
+	}
+
+	testCases := []struct {
+		Name             string
+		Options          stripOptions
+		ExpectedAdapters []string
+		ExpectedDisks    []string
+	}{
+		{
+			Name:             "both by default",
+			Options:          stripOptions{},
+			ExpectedAdapters: []string{"vlan2", "vlan3"},
+			ExpectedDisks:    []string{"data-disk", "second-controller-disk"},
+		},
+		{
+			Name:             "both explicitly",
+			Options:          stripOptions{NICs: true, Disks: true},
+			ExpectedAdapters: []string{"vlan2", "vlan3"},
+			ExpectedDisks:    []string{"data-disk", "second-controller-disk"},
+		},
+		{
+			Name:             "network adapters only",
+			Options:          stripOptions{NICs: true},
+			ExpectedAdapters: []string{"vlan2", "vlan3"},
+		},
+		{
+			Name:          "disks only",
+			Options:       stripOptions{Disks: true},
+			ExpectedDisks: []string{"data-disk", "second-controller-disk"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		networkAdapters, disks := strippableResources(server, testCase.Options)
+
+		var actualAdapters []string
+		for _, networkAdapter := range networkAdapters {
+			actualAdapters = append(actualAdapters, *networkAdapter.VLANID)
+		}
+		if !equalStrings(actualAdapters, testCase.ExpectedAdapters) {
+			t.Errorf("%s: expected network adapters %v, but got %v", testCase.Name, testCase.ExpectedAdapters, actualAdapters)
+		}
+
+		var actualDisks []string
+		for _, disk := range disks {
+			actualDisks = append(actualDisks, *disk.ID)
+		}
+		if !equalStrings(actualDisks, testCase.ExpectedDisks) {
+			t.Errorf("%s: expected disks %v, but got %v", testCase.Name, testCase.ExpectedDisks, actualDisks)
+		}
+	}
+
+	_, disks := strippableResources(server, stripOptions{Disks: true})
+	if disks[1].SCSIBusNumber != 1 || disks[1].SCSIUnitID != 0 {
+		t.Errorf("Expected disk on SCSI controller 1, unit 0, but got controller %d, unit %d", disks[1].SCSIBusNumber, disks[1].SCSIUnitID)
+	}
+}
+
+func TestStrippableResourcesWithNothingToRemove(t *testing.T) {
+	server := compute.Server{
+		SCSIControllers: compute.VirtualMachineSCSIControllers{
+			{BusNumber: 0, Disks: []compute.VirtualMachineDisk{testDisk("os-disk", 0)}},
+		},
+	}
+	server.Network.PrimaryAdapter = testNetworkAdapter("vlan1", "Primary")
+
+	networkAdapters, disks := strippableResources(server, stripOptions{})
+	if len(networkAdapters) != 0 || len(disks) != 0 {
+		t.Errorf("Expected nothing to remove, but got %d network adapter(s) and %d disk(s)", len(networkAdapters), len(disks))
+	}
+}
+
+func testDisk(id string, scsiUnitID int) compute.VirtualMachineDisk {
+	return compute.VirtualMachineDisk{
+		ID:         &id,
+		SCSIUnitID: scsiUnitID,
+		SizeGB:     10,
+		Speed:      "STANDARD",
+	}
+}
+
+func equalStrings(actual []string, expected []string) bool {
+	if len(actual) != len(expected) {
+		return false
+	}
+	for index := range actual {
+		if actual[index] != expected[index] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,134 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// The summary of the current run.
+var summary = &runSummary{}
+
+// runSummary collects the results of a run (that are displayed when it finishes).
+//
+// It is safe to update from multiple goroutines.
+type runSummary struct {
+	lock                sync.Mutex
+	serverBackups       []serverBackup
+	backupSubscriptions []backupSubscription
+	inventoryFiles      []writtenFile
+	reportFiles         []writtenFile
+}
+
+// A writtenFile records the location of a file (e.g. an inventory or report) written for a network domain.
+type writtenFile struct {
+	NetworkDomainName string
+	Path              string
+}
+
+// AddServerBackup records a customer image cloned from a server before it was destroyed.
+func (runSummary *runSummary) AddServerBackup(backup serverBackup) {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	runSummary.serverBackups = append(runSummary.serverBackups, backup)
+}
+
+// ServerBackups returns the customer images cloned from servers before they were destroyed.
+func (runSummary *runSummary) ServerBackups() []serverBackup {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	return append([]serverBackup(nil), runSummary.serverBackups...)
+}
+
+// AddBackupSubscription records a Cloud Backup subscription that was removed from a server.
+func (runSummary *runSummary) AddBackupSubscription(subscription backupSubscription) {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	runSummary.backupSubscriptions = append(runSummary.backupSubscriptions, subscription)
+}
+
+// BackupSubscriptions returns the Cloud Backup subscriptions that were removed from servers.
+func (runSummary *runSummary) BackupSubscriptions() []backupSubscription {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	return append([]backupSubscription(nil), runSummary.backupSubscriptions...)
+}
+
+// AddInventoryFile records the location of the inventory written for a network domain.
+func (runSummary *runSummary) AddInventoryFile(networkDomainName string, path string) {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	runSummary.inventoryFiles = append(runSummary.inventoryFiles, writtenFile{
+		NetworkDomainName: networkDomainName,
+		Path:              path,
+	})
+}
+
+// InventoryFiles returns the locations of the inventories written during the run.
+func (runSummary *runSummary) InventoryFiles() []writtenFile {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	return append([]writtenFile(nil), runSummary.inventoryFiles...)
+}
+
+// AddReportFile records the location of the report written for a network domain.
+func (runSummary *runSummary) AddReportFile(networkDomainName string, path string) {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	runSummary.reportFiles = append(runSummary.reportFiles, writtenFile{
+		NetworkDomainName: networkDomainName,
+		Path:              path,
+	})
+}
+
+// ReportFiles returns the locations of the reports written during the run.
+func (runSummary *runSummary) ReportFiles() []writtenFile {
+	runSummary.lock.Lock()
+	defer runSummary.lock.Unlock()
+
+	return append([]writtenFile(nil), runSummary.reportFiles...)
+}
+
+// Display the summary of the current run.
+func showSummary() {
+	for _, backup := range summary.ServerBackups() {
+		fmt.Fprintf(console, "Server '%s' ('%s') was backed up to customer image '%s' ('%s').\n",
+			backup.ServerName,
+			backup.ServerID,
+			backup.ImageName,
+			backup.ImageID,
+		)
+	}
+
+	showBackupSubscriptionSummary(summary.BackupSubscriptions())
+
+	for _, inventoryFile := range summary.InventoryFiles() {
+		fmt.Fprintf(console, "Inventory of network domain '%s' written to '%s'.\n", inventoryFile.NetworkDomainName, inventoryFile.Path)
+	}
+
+	for _, reportFile := range summary.ReportFiles() {
+		fmt.Fprintf(console, "Report for network domain '%s' written to '%s'.\n", reportFile.NetworkDomainName, reportFile.Path)
+	}
+}
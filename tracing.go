@@ -0,0 +1,323 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Records spans for the nuke in progress (only when --trace-file or --trace-endpoint is specified).
+var tracer = &traceRecorder{}
+
+// traceRecorder collects the spans that make up a trace of a nuke, and exports them in OTLP (JSON) format.
+//
+// It is safe to use from multiple goroutines.
+type traceRecorder struct {
+	lock      sync.Mutex
+	enabled   bool
+	traceID   string
+	spans     []*span
+	rootSpan  *span
+	stageSpan *span
+}
+
+// A span represents a single timed operation (e.g. a stage, or deleting a server) within a trace.
+type span struct {
+	recorder     *traceRecorder
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Attributes   map[string]string
+	Start        time.Time
+	End          time.Time
+	Error        string
+}
+
+// Enable the trace recorder.
+func enableTracing() {
+	tracer.enabled = true
+}
+
+// StartTrace starts a new trace, returning its root span (or nil, if tracing is not enabled).
+func (recorder *traceRecorder) StartTrace(name string, attributes map[string]string) *span {
+	recorder.lock.Lock()
+	if !recorder.enabled {
+		recorder.lock.Unlock()
+
+		return nil
+	}
+	recorder.traceID = newTraceID(16)
+	recorder.spans = nil
+	recorder.rootSpan = nil
+	recorder.stageSpan = nil
+	recorder.lock.Unlock()
+
+	rootSpan := recorder.StartSpan(nil, name, attributes)
+
+	recorder.lock.Lock()
+	recorder.rootSpan = rootSpan
+	recorder.lock.Unlock()
+
+	return rootSpan
+}
+
+// StartSpan starts a new span (if parent is nil, the span for the current stage or, failing that, the trace's root span is used as its parent).
+func (recorder *traceRecorder) StartSpan(parent *span, name string, attributes map[string]string) *span {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	if !recorder.enabled || recorder.traceID == "" {
+		return nil
+	}
+	if parent == nil {
+		parent = recorder.stageSpan
+	}
+	if parent == nil {
+		parent = recorder.rootSpan
+	}
+
+	newSpan := &span{
+		recorder:   recorder,
+		TraceID:    recorder.traceID,
+		SpanID:     newTraceID(8),
+		Name:       name,
+		Attributes: attributes,
+		Start:      time.Now(),
+	}
+	if parent != nil {
+		newSpan.ParentSpanID = parent.SpanID
+	}
+	recorder.spans = append(recorder.spans, newSpan)
+
+	return newSpan
+}
+
+// SetStageSpan sets the span for the current stage (the default parent for new spans).
+func (recorder *traceRecorder) SetStageSpan(stageSpan *span) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	recorder.stageSpan = stageSpan
+}
+
+// Finish ends a span.
+func (span *span) Finish(err error) {
+	if span == nil {
+		return
+	}
+
+	span.recorder.lock.Lock()
+	defer span.recorder.lock.Unlock()
+
+	span.End = time.Now()
+	if err != nil {
+		span.Error = err.Error()
+	}
+}
+
+// Export writes the current trace to the trace file and / or posts it to the OTLP collector.
+func (recorder *traceRecorder) Export(traceFile string, traceEndpoint string) error {
+	recorder.lock.Lock()
+	if !recorder.enabled || recorder.traceID == "" {
+		recorder.lock.Unlock()
+
+		return nil
+	}
+	request := recorder.buildExportRequest()
+	recorder.traceID = ""
+	recorder.spans = nil
+	recorder.rootSpan = nil
+	recorder.stageSpan = nil
+	recorder.lock.Unlock()
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	if traceFile != "" {
+		// One export request per line, so that traces from successive nukes can be appended to the same file.
+		file, err := os.OpenFile(traceFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(append(data, '\n'))
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if traceEndpoint != "" {
+		client := &http.Client{Timeout: 30 * time.Second}
+		response, err := client.Post(traceEndpoint, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("Trace collector returned unexpected status '%s'.", response.Status)
+		}
+	}
+
+	return nil
+}
+
+// The following types represent the OTLP (JSON) trace export request; see https://opentelemetry.io/docs/specs/otlp/.
+
+type otlpExportRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// OTLP span kind and status codes.
+const (
+	otlpSpanKindInternal = 1
+	otlpStatusCodeOK     = 1
+	otlpStatusCodeError  = 2
+)
+
+// Build the OTLP export request for the current trace (the caller must hold the lock).
+func (recorder *traceRecorder) buildExportRequest() otlpExportRequest {
+	now := time.Now()
+	spans := make([]otlpSpan, len(recorder.spans))
+	for index, span := range recorder.spans {
+		end := span.End
+		if end.IsZero() {
+			end = now // Unfinished (e.g. abandoned when a stage failed).
+		}
+
+		spans[index] = otlpSpan{
+			TraceID:           span.TraceID,
+			SpanID:            span.SpanID,
+			ParentSpanID:      span.ParentSpanID,
+			Name:              span.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+			Status:            otlpStatus{Code: otlpStatusCodeOK},
+		}
+		if span.Error != "" {
+			spans[index].Status = otlpStatus{Code: otlpStatusCodeError, Message: span.Error}
+		}
+	}
+
+	return otlpExportRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{
+					Attributes: otlpAttributes(map[string]string{
+						"service.name":    "nifo",
+						"service.version": ProductVersion,
+					}),
+				},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: "nifo", Version: ProductVersion},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// Convert span attributes to OTLP attributes (sorted by key, and omitting empty values).
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key, value := range attributes {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	otlpAttributes := make([]otlpAttribute, len(keys))
+	for index, key := range keys {
+		otlpAttributes[index] = otlpAttribute{
+			Key:   key,
+			Value: otlpAttributeValue{StringValue: attributes[key]},
+		}
+	}
+
+	return otlpAttributes
+}
+
+// Generate a random trace or span Id (hex-encoded).
+func newTraceID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
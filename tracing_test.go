@@ -0,0 +1,280 @@
+/*
+   Copyright 2016 Dimension Data
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	traceIDPattern = regexp.MustCompile("^[0-9a-f]{32}$")
+	spanIDPattern  = regexp.MustCompile("^[0-9a-f]{16}$")
+)
+
+// Record a test trace with a root span, a stage span, and a failed child span (the root span is left unfinished).
+func recordTestTrace() *traceRecorder {
+	recorder := &traceRecorder{enabled: true}
+
+	rootSpan := recorder.StartTrace("nuke", map[string]string{
+		"nifo.networkdomain.id": "my-domain-id",
+		"nifo.datacenter":       "",
+	})
+	rootSpan.Start = time.Unix(1478000000, 0)
+
+	stageSpan := recorder.StartSpan(nil, "stage natrules", map[string]string{"nifo.stage": "natrules"})
+	stageSpan.Start = time.Unix(1478000001, 500)
+	recorder.SetStageSpan(stageSpan)
+
+	actionSpan := recorder.StartSpan(nil, "delete natRule", map[string]string{
+		"nifo.resource.type": "natRule",
+		"nifo.action":        "delete",
+	})
+	actionSpan.Start = time.Unix(1478000002, 0)
+	actionSpan.Finish(fmt.Errorf("Resource is busy."))
+	actionSpan.End = time.Unix(1478000003, 250)
+
+	stageSpan.Finish(nil)
+	stageSpan.End = time.Unix(1478000004, 0)
+
+	return recorder
+}
+
+func TestBuildExportRequest(t *testing.T) {
+	recorder := recordTestTrace()
+	request := recorder.buildExportRequest()
+
+	// Check the JSON encoding, since that is what collectors see.
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []otlpAttribute `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Scope struct {
+					Name string `json:"name"`
+				} `json:"scope"`
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.ResourceSpans) != 1 || len(decoded.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Expected 1 resource span with 1 scope span, but got: %s", data)
+	}
+	resourceAttributes := decoded.ResourceSpans[0].Resource.Attributes
+	if len(resourceAttributes) != 2 || resourceAttributes[0].Key != "service.name" || resourceAttributes[0].Value.StringValue != "nifo" {
+		t.Errorf("Unexpected resource attributes %+v", resourceAttributes)
+	}
+	scopeSpans := decoded.ResourceSpans[0].ScopeSpans[0]
+	if scopeSpans.Scope.Name != "nifo" {
+		t.Errorf("Expected scope 'nifo', but got '%s'", scopeSpans.Scope.Name)
+	}
+
+	spans := scopeSpans.Spans
+	if len(spans) != 3 {
+		t.Fatalf("Expected 3 spans, but got %d", len(spans))
+	}
+	rootSpan, stageSpan, actionSpan := spans[0], spans[1], spans[2]
+
+	traceID, _ := rootSpan["traceId"].(string)
+	if !traceIDPattern.MatchString(traceID) {
+		t.Errorf("Expected a 16-byte hex trace Id, but got '%s'", traceID)
+	}
+	spanIDs := make(map[string]bool)
+	for _, span := range spans {
+		if span["traceId"] != traceID {
+			t.Errorf("Span '%s' has trace Id '%s' (expected '%s')", span["name"], span["traceId"], traceID)
+		}
+		spanID, _ := span["spanId"].(string)
+		if !spanIDPattern.MatchString(spanID) || spanIDs[spanID] {
+			t.Errorf("Span '%s' has invalid or duplicate span Id '%s'", span["name"], spanID)
+		}
+		spanIDs[spanID] = true
+		if span["kind"] != float64(otlpSpanKindInternal) {
+			t.Errorf("Span '%s' has kind %v (expected %d)", span["name"], span["kind"], otlpSpanKindInternal)
+		}
+	}
+
+	if _, hasParent := rootSpan["parentSpanId"]; hasParent {
+		t.Errorf("Expected the root span to have no parent, but got '%s'", rootSpan["parentSpanId"])
+	}
+	if stageSpan["parentSpanId"] != rootSpan["spanId"] {
+		t.Errorf("Expected the stage span's parent to be the root span")
+	}
+	if actionSpan["parentSpanId"] != stageSpan["spanId"] {
+		t.Errorf("Expected the action span's parent to be the stage span")
+	}
+
+	// Timestamps are nanoseconds since the epoch, encoded as strings.
+	timestampTestCases := []struct {
+		Span     map[string]interface{}
+		Field    string
+		Expected string
+	}{
+		{rootSpan, "startTimeUnixNano", "1478000000000000000"},
+		{stageSpan, "startTimeUnixNano", "1478000001000000500"},
+		{stageSpan, "endTimeUnixNano", "1478000004000000000"},
+		{actionSpan, "startTimeUnixNano", "1478000002000000000"},
+		{actionSpan, "endTimeUnixNano", "1478000003000000250"},
+	}
+	for _, testCase := range timestampTestCases {
+		if testCase.Span[testCase.Field] != testCase.Expected {
+			t.Errorf("Span '%s': expected %s '%s', but got %#v", testCase.Span["name"], testCase.Field, testCase.Expected, testCase.Span[testCase.Field])
+		}
+	}
+
+	// Unfinished spans end when the trace is exported.
+	rootEnd, err := strconv.ParseInt(rootSpan["endTimeUnixNano"].(string), 10, 64)
+	if err != nil || time.Since(time.Unix(0, rootEnd)) > time.Minute {
+		t.Errorf("Expected the unfinished root span to end now, but got '%s'", rootSpan["endTimeUnixNano"])
+	}
+
+	statusTestCases := []struct {
+		Span    map[string]interface{}
+		Code    int
+		Message string
+	}{
+		{rootSpan, otlpStatusCodeOK, ""},
+		{stageSpan, otlpStatusCodeOK, ""},
+		{actionSpan, otlpStatusCodeError, "Resource is busy."},
+	}
+	for _, testCase := range statusTestCases {
+		status, _ := testCase.Span["status"].(map[string]interface{})
+		message, _ := status["message"].(string)
+		if status["code"] != float64(testCase.Code) || message != testCase.Message {
+			t.Errorf("Span '%s': expected status %d ('%s'), but got %v", testCase.Span["name"], testCase.Code, testCase.Message, status)
+		}
+	}
+
+	// Attributes are sorted by key, and empty values are omitted.
+	expectedAttributes := `[{"key":"nifo.action","value":{"stringValue":"delete"}},{"key":"nifo.resource.type","value":{"stringValue":"natRule"}}]`
+	if actual, _ := json.Marshal(actionSpan["attributes"]); string(actual) != expectedAttributes {
+		t.Errorf("Expected action span attributes %s, but got %s", expectedAttributes, actual)
+	}
+	if actual, _ := json.Marshal(rootSpan["attributes"]); string(actual) != `[{"key":"nifo.networkdomain.id","value":{"stringValue":"my-domain-id"}}]` {
+		t.Errorf("Expected the empty root span attribute to be omitted, but got %s", actual)
+	}
+}
+
+func TestTraceExport(t *testing.T) {
+	directory, err := ioutil.TempDir("", "nifo-trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(directory)
+	traceFile := filepath.Join(directory, "trace.json")
+
+	var (
+		requestCount       int
+		requestContentType string
+		requestBody        string
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requestCount++
+		requestContentType = request.Header.Get("Content-Type")
+		body, _ := ioutil.ReadAll(request.Body)
+		requestBody = string(body)
+	}))
+	defer collector.Close()
+
+	recorder := recordTestTrace()
+	err = recorder.Export(traceFile, collector.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = recordTestTrace().Export(traceFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if requestCount != 1 || requestContentType != "application/json" {
+		t.Fatalf("Expected 1 JSON request to the collector, but got %d ('%s')", requestCount, requestContentType)
+	}
+	var exported otlpExportRequest
+	err = json.Unmarshal([]byte(requestBody), &exported)
+	if err != nil {
+		t.Fatalf("Collector received invalid JSON: %s", err)
+	}
+	if spans := exported.ResourceSpans[0].ScopeSpans[0].Spans; len(spans) != 3 || spans[0].Name != "nuke" {
+		t.Errorf("Expected the collector to receive 3 spans, but got %+v", spans)
+	}
+
+	// Each trace is appended to the file as a single line.
+	data, err := ioutil.ReadFile(traceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) != 2 || lines[0] != requestBody {
+		t.Fatalf("Expected 2 traces in the trace file (the first matching the collector's), but got:\n%s", data)
+	}
+
+	// Exporting clears the trace, so it is not exported again.
+	err = recorder.Export(traceFile, collector.URL)
+	if err != nil || requestCount != 1 {
+		t.Errorf("Expected nothing to export after the trace was exported, but got %d request(s) (%v)", requestCount, err)
+	}
+	if span := recorder.StartSpan(nil, "orphan", nil); span != nil {
+		t.Errorf("Expected no span outside a trace, but got '%s'", span.Name)
+	}
+}
+
+func TestTraceExportCollectorFailure(t *testing.T) {
+	collector := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		http.Error(response, "Collector unavailable.", http.StatusServiceUnavailable)
+	}))
+	defer collector.Close()
+
+	err := recordTestTrace().Export("", collector.URL)
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected an error for the collector's 503 response, but got %v", err)
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	recorder := &traceRecorder{}
+	if rootSpan := recorder.StartTrace("nuke", nil); rootSpan != nil {
+		t.Errorf("Expected no trace when tracing is disabled")
+	}
+
+	// Finishing a nil span is a no-op.
+	var nilSpan *span
+	nilSpan.Finish(nil)
+
+	err := recorder.Export("", "http://127.0.0.1:1/")
+	if err != nil {
+		t.Errorf("Expected nothing to export when tracing is disabled, but got %s", err)
+	}
+}